@@ -13,11 +13,15 @@ import (
 
 const defaultAddr = ":6060"
 
-var httpAddr = flag.String("http", "", "HTTP service address (e.g. '"+defaultAddr+"')")
+var (
+	httpAddr   = flag.String("http", "", "HTTP service address (e.g. '"+defaultAddr+"')")
+	showSource = flag.Bool("source", false, "underline the source of each item in the pattern")
+)
 
 // Usage prints a usage of this command.
 func Usage() {
-	fmt.Fprintln(os.Stderr, "command: factors <regexp_pattern>")
+	fmt.Fprintln(os.Stderr, "command: factors [-source] <regexp_pattern>")
+	fmt.Fprintln(os.Stderr, "         factors explain <regexp_pattern>")
 	fmt.Fprintln(os.Stderr, "server:  factors -http="+defaultAddr)
 	flag.PrintDefaults()
 }
@@ -26,7 +30,15 @@ func Usage() {
 func Run() error {
 	flag.Usage = Usage
 	flag.Parse()
-	if len(os.Args) != 2 && *httpAddr == "" {
+	if flag.NArg() == 2 && flag.Arg(0) == "explain" {
+		re, err := syntax.Parse(flag.Arg(1), syntax.Perl)
+		if err != nil {
+			return err
+		}
+		factors.NewAnalyzer().Parse(re).Explain(os.Stdout)
+		return nil
+	}
+	if flag.NArg() != 1 && *httpAddr == "" {
 		Usage()
 		os.Exit(1)
 	}
@@ -37,7 +49,10 @@ func Run() error {
 		http.HandleFunc("/_demo", demoHandler)
 		log.Fatal(http.ListenAndServe(*httpAddr, nil))
 	}
-	re, err := syntax.Parse(os.Args[1], syntax.Perl)
+	if *showSource {
+		return printSource(flag.Arg(0))
+	}
+	re, err := syntax.Parse(flag.Arg(0), syntax.Perl)
 	if err != nil {
 		return err
 	}
@@ -51,3 +66,24 @@ func Run() error {
 
 	return nil
 }
+
+// printSource prints the factors, and underlines the source of each item in the pattern.
+func printSource(pattern string) error {
+	f, err := factors.NewAnalyzer().FactorWithSource(pattern)
+	if err != nil {
+		return err
+	}
+	for _, v := range namedSets(f) {
+		fmt.Printf("%s: %s\n", v.name, v.set)
+		items := v.set.Items()
+		if len(items) > maxSourceItems {
+			items = items[:maxSourceItems]
+		}
+		for _, item := range items {
+			fmt.Printf("  %q\n", item)
+			fmt.Printf("    %s\n", pattern)
+			fmt.Printf("    %s\n", underline(pattern, v.set.Source(item)))
+		}
+	}
+	return nil
+}
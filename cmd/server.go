@@ -78,9 +78,14 @@ func demoHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 END:
+	var sources []source
+	if len(input) != 0 && cmdErr == "" {
+		sources = highlightSources(input)
+	}
 	d := struct {
 		Regexp                          string
 		Exact, Prefix, Suffix, Fragment string
+		Sources                         []source
 		CmdErr                          string
 		GraphSvg                        template.HTML
 	}{
@@ -89,6 +94,7 @@ END:
 		Prefix:   strings.Join(f.Prefix.Items(), ","),
 		Suffix:   strings.Join(f.Suffix.Items(), ","),
 		Fragment: strings.Join(f.Fragment.Items(), ","),
+		Sources:  sources,
 		CmdErr:   cmdErr,
 		GraphSvg: template.HTML(svg),
 	}
@@ -96,3 +102,28 @@ END:
 		http.Error(w, e.Error(), http.StatusInternalServerError)
 	}
 }
+
+// source is an item of the factors with the highlighted source in the pattern.
+type source struct {
+	Set, Item string
+	Pattern   template.HTML
+}
+
+// highlightSources returns the items of the factors of a pattern with their highlighted sources.
+func highlightSources(pattern string) []source {
+	f, err := factors.NewAnalyzer().FactorWithSource(pattern)
+	if err != nil {
+		return nil
+	}
+	var ret []source
+	for _, v := range namedSets(f) {
+		items := v.set.Items()
+		if len(items) > maxSourceItems {
+			items = items[:maxSourceItems]
+		}
+		for _, item := range items {
+			ret = append(ret, source{Set: v.name, Item: item, Pattern: highlight(pattern, v.set.Source(item))})
+		}
+	}
+	return ret
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"html"
+	"html/template"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ikawaha/factors/factors"
+)
+
+// maxSourceItems is the maximum number of items of a set whose sources are shown.
+const maxSourceItems = 100
+
+type namedSet struct {
+	name string
+	set  factors.Set
+}
+
+func namedSets(f factors.Factor) []namedSet {
+	return []namedSet{
+		{name: "Exact", set: f.Exact},
+		{name: "Prefix", set: f.Prefix},
+		{name: "Suffix", set: f.Suffix},
+		{name: "Fragment", set: f.Fragment},
+	}
+}
+
+// underline returns a line which marks the spans of the pattern with ^.
+func underline(pattern string, spans []factors.Span) string {
+	var b strings.Builder
+	end := 0
+	for _, v := range spans {
+		b.WriteString(strings.Repeat(" ", utf8.RuneCountInString(pattern[end:v.Start])))
+		b.WriteString(strings.Repeat("^", utf8.RuneCountInString(pattern[v.Start:v.End])))
+		end = v.End
+	}
+	return b.String()
+}
+
+// highlight returns the pattern in HTML which underlines the spans.
+func highlight(pattern string, spans []factors.Span) template.HTML {
+	var b strings.Builder
+	end := 0
+	for _, v := range spans {
+		b.WriteString(html.EscapeString(pattern[end:v.Start]))
+		b.WriteString("<u>")
+		b.WriteString(html.EscapeString(pattern[v.Start:v.End]))
+		b.WriteString("</u>")
+		end = v.End
+	}
+	b.WriteString(html.EscapeString(pattern[end:]))
+	return template.HTML(b.String()) //nolint:gosec
+}
@@ -0,0 +1,607 @@
+// Package ahocorasick implements the Aho-Corasick automaton to find many literals at once,
+// e.g. the items of the necessary factor sets of regexps.
+package ahocorasick
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+)
+
+// Set represents a set of literals, e.g. factors.Set.
+type Set interface {
+	Items() []string
+}
+
+// Match represents an occurrence of a pattern.
+type Match struct {
+	// Pattern is the index of the pattern.
+	Pattern int
+	// Start and End are the byte range [Start, End) of the occurrence in the text.
+	Start, End int
+}
+
+// Automaton is a compiled Aho-Corasick automaton. It is never modified after it is built,
+// so it is safe for concurrent use. Empty patterns are never reported.
+type Automaton struct {
+	patterns []string
+	// sets is the indexes of the first patterns of the sets, nil if it is not built from sets.
+	sets  []int
+	nodes []node
+	edges []edge
+	// root is the transitions of the root, 0 for the bytes which no pattern begins with.
+	root [256]int32
+	// same is the next pattern of the same literal, -1 if none.
+	same []int32
+	// maxLen is the length of the longest pattern.
+	maxLen int
+}
+
+type node struct {
+	// edges[begin:end] is the transitions of the node, sorted by the labels.
+	begin, end int32
+	fail       int32
+	out        int32 // the first pattern which ends at the node, -1 if none.
+	dict       int32 // the nearest node with an output on the fail chain, -1 if none.
+}
+
+type edge struct {
+	label byte
+	to    int32
+}
+
+// New builds an automaton of the patterns, the indexes of the patterns are the ones of the slice.
+//
+//nolint:gocyclo
+func New(patterns []string) *Automaton {
+	// Build a trie with the edges of each node.
+	type trieNode struct {
+		children map[byte]int32
+		out      int32
+	}
+	trie := []trieNode{{out: -1}}
+	same := make([]int32, len(patterns))
+	last := map[string]int32{}
+	var maxLen int
+	for id, p := range patterns {
+		same[id] = -1
+		if len(p) > maxLen {
+			maxLen = len(p)
+		}
+		n := int32(0)
+		for i := 0; i < len(p); i++ {
+			next, ok := trie[n].children[p[i]]
+			if !ok {
+				next = int32(len(trie))
+				trie = append(trie, trieNode{out: -1})
+				if trie[n].children == nil {
+					trie[n].children = map[byte]int32{}
+				}
+				trie[n].children[p[i]] = next
+			}
+			n = next
+		}
+		if p == "" {
+			continue
+		}
+		if prev, ok := last[p]; ok {
+			same[prev] = int32(id)
+		} else {
+			trie[n].out = int32(id)
+		}
+		last[p] = int32(id)
+	}
+	a := &Automaton{
+		patterns: patterns,
+		nodes:    make([]node, len(trie)),
+		same:     same,
+		maxLen:   maxLen,
+	}
+	for i, v := range trie {
+		labels := make([]int, 0, len(v.children))
+		for k := range v.children {
+			labels = append(labels, int(k))
+		}
+		sort.Ints(labels)
+		a.nodes[i] = node{begin: int32(len(a.edges)), fail: 0, out: v.out, dict: -1}
+		for _, k := range labels {
+			a.edges = append(a.edges, edge{label: byte(k), to: v.children[byte(k)]})
+		}
+		a.nodes[i].end = int32(len(a.edges))
+	}
+	for _, e := range a.nodeEdges(0) {
+		a.root[e.label] = e.to
+	}
+	// Build the fail links in breadth first order.
+	queue := make([]int32, 0, len(a.nodes))
+	for _, e := range a.nodeEdges(0) {
+		queue = append(queue, e.to)
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range a.nodeEdges(n) {
+			f := a.nodes[n].fail
+			for {
+				if next, ok := a.child(f, e.label); ok && next != e.to {
+					a.nodes[e.to].fail = next
+					break
+				}
+				if f == 0 {
+					break
+				}
+				f = a.nodes[f].fail
+			}
+			if f := a.nodes[e.to].fail; a.nodes[f].out >= 0 {
+				a.nodes[e.to].dict = f
+			} else {
+				a.nodes[e.to].dict = a.nodes[f].dict
+			}
+			queue = append(queue, e.to)
+		}
+	}
+	return a
+}
+
+// NewFromSets builds an automaton of the items of the sets. The patterns are the items of the sets in order,
+// and SetOf returns the set of a pattern. An infinite set of factors has no items, so it has no patterns.
+func NewFromSets(sets ...Set) *Automaton {
+	var patterns []string
+	offsets := make([]int, len(sets))
+	for i, s := range sets {
+		offsets[i] = len(patterns)
+		patterns = append(patterns, s.Items()...)
+	}
+	a := New(patterns)
+	a.sets = offsets
+	return a
+}
+
+// Len returns the number of the patterns.
+func (a *Automaton) Len() int {
+	return len(a.patterns)
+}
+
+// Pattern returns the pattern of a given index.
+func (a *Automaton) Pattern(i int) string {
+	return a.patterns[i]
+}
+
+// SetOf returns the index of the set which has a given pattern, -1 if the automaton is not built from sets.
+func (a *Automaton) SetOf(pattern int) int {
+	if a.sets == nil {
+		return -1
+	}
+	return sort.Search(len(a.sets), func(i int) bool { return a.sets[i] > pattern }) - 1
+}
+
+func (a *Automaton) nodeEdges(n int32) []edge {
+	return a.edges[a.nodes[n].begin:a.nodes[n].end]
+}
+
+func (a *Automaton) child(n int32, label byte) (int32, bool) {
+	edges := a.nodeEdges(n)
+	if len(edges) <= 8 {
+		for _, e := range edges {
+			if e.label == label {
+				return e.to, true
+			}
+		}
+		return 0, false
+	}
+	i := sort.Search(len(edges), func(i int) bool { return edges[i].label >= label })
+	if i < len(edges) && edges[i].label == label {
+		return edges[i].to, true
+	}
+	return 0, false
+}
+
+func (a *Automaton) step(n int32, b byte) int32 {
+	for {
+		if n == 0 {
+			return a.root[b]
+		}
+		if next, ok := a.child(n, b); ok {
+			return next
+		}
+		n = a.nodes[n].fail
+	}
+}
+
+// scanner keeps the state of a scan to feed a text in chunks.
+type scanner struct {
+	a     *Automaton
+	state int32
+	pos   int
+}
+
+// feed scans a chunk of the text and calls fn for the occurrences which end in the chunk.
+// It returns false if fn stops.
+func (s *scanner) feed(chunk []byte, fn func(m Match) bool) bool {
+	a := s.a
+	for i := 0; i < len(chunk); i++ {
+		if s.state == 0 {
+			// Skip the bytes which no pattern begins with.
+			for i < len(chunk) && a.root[chunk[i]] == 0 {
+				i++
+			}
+			if i == len(chunk) {
+				break
+			}
+		}
+		s.state = a.step(s.state, chunk[i])
+		if !a.emit(s.state, s.pos+i+1, fn) {
+			return false
+		}
+	}
+	s.pos += len(chunk)
+	return true
+}
+
+// emit calls fn for the patterns which end at the node n, and returns false if fn stops.
+func (a *Automaton) emit(n int32, end int, fn func(m Match) bool) bool {
+	for ; n > 0; n = a.nodes[n].dict {
+		for id := a.nodes[n].out; id >= 0; id = a.same[id] {
+			if !fn(Match{Pattern: int(id), Start: end - len(a.patterns[id]), End: end}) {
+				return false
+			}
+		}
+		if a.nodes[n].dict < 0 {
+			break
+		}
+	}
+	return true
+}
+
+// Overlapping calls fn for every occurrence of the patterns in the text in the order of the ends,
+// the occurrences which end at the same position are in the order of decreasing lengths.
+// It stops if fn returns false.
+func (a *Automaton) Overlapping(b []byte, fn func(m Match) bool) {
+	s := scanner{a: a}
+	s.feed(b, fn)
+}
+
+// OverlappingString is Overlapping for a text of a string.
+func (a *Automaton) OverlappingString(str string, fn func(m Match) bool) {
+	var state int32
+	for i := 0; i < len(str); i++ {
+		if state == 0 {
+			for i < len(str) && a.root[str[i]] == 0 {
+				i++
+			}
+			if i == len(str) {
+				return
+			}
+		}
+		state = a.step(state, str[i])
+		if !a.emit(state, i+1, fn) {
+			return
+		}
+	}
+}
+
+// OverlappingReader is Overlapping for a text read from r. The positions are the offsets from the start of r.
+func (a *Automaton) OverlappingReader(r io.Reader, fn func(m Match) bool) error {
+	s := scanner{a: a}
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 && !s.feed(buf[:n], fn) {
+			return nil
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// leftmostFirst selects the leftmost-first occurrences out of the overlapping occurrences.
+// Among the occurrences which start at the leftmost position, the one of the smallest pattern index wins,
+// and the next occurrence starts at or after the end of it.
+type leftmostFirst struct {
+	maxLen  int
+	floor   int
+	pending []Match
+	emit    func(m Match) bool
+}
+
+func better(x, y Match) bool {
+	return x.Start < y.Start || (x.Start == y.Start && x.Pattern < y.Pattern)
+}
+
+// add adds an occurrence which ends at pos, and emits the occurrences decided.
+func (l *leftmostFirst) add(m Match) bool {
+	if m.Start >= l.floor {
+		l.pending = append(l.pending, m)
+	}
+	// An occurrence which beats the best one ends at or before the best start plus the longest length.
+	return l.flush(m.End, false)
+}
+
+// flush emits the best occurrences which no later occurrence beats, or all at the end of the text.
+func (l *leftmostFirst) flush(pos int, eof bool) bool {
+	for len(l.pending) > 0 {
+		best := l.pending[0]
+		for _, v := range l.pending[1:] {
+			if better(v, best) {
+				best = v
+			}
+		}
+		// The occurrences which end at pos may not be added yet.
+		if !eof && pos <= best.Start+l.maxLen {
+			return true
+		}
+		if !l.emit(best) {
+			return false
+		}
+		l.floor = best.End
+		w := 0
+		for _, v := range l.pending {
+			if v.Start >= l.floor {
+				l.pending[w] = v
+				w++
+			}
+		}
+		l.pending = l.pending[:w]
+	}
+	return true
+}
+
+// Find returns the leftmost-first occurrence of the patterns in the text.
+func (a *Automaton) Find(b []byte) (Match, bool) {
+	var ret Match
+	var found bool
+	a.LeftmostFirst(b, func(m Match) bool {
+		ret, found = m, true
+		return false
+	})
+	return ret, found
+}
+
+// FindAll returns the successive non-overlapping leftmost-first occurrences of the patterns in the text.
+func (a *Automaton) FindAll(b []byte) []Match {
+	var ret []Match
+	a.LeftmostFirst(b, func(m Match) bool {
+		ret = append(ret, m)
+		return true
+	})
+	return ret
+}
+
+// LeftmostFirst calls fn for the successive non-overlapping leftmost-first occurrences of the patterns in the text.
+// It stops if fn returns false.
+func (a *Automaton) LeftmostFirst(b []byte, fn func(m Match) bool) {
+	l := leftmostFirst{maxLen: a.maxLen, emit: fn}
+	s := scanner{a: a}
+	if s.feed(b, l.add) {
+		l.flush(len(b), true)
+	}
+}
+
+// LeftmostFirstReader is LeftmostFirst for a text read from r. The positions are the offsets from the start of r.
+func (a *Automaton) LeftmostFirstReader(r io.Reader, fn func(m Match) bool) error {
+	l := leftmostFirst{maxLen: a.maxLen, emit: fn}
+	var stopped bool
+	var pos int
+	err := a.OverlappingReader(&countingReader{r: r, n: &pos}, func(m Match) bool {
+		stopped = !l.add(m)
+		return !stopped
+	})
+	if err != nil || stopped {
+		return err
+	}
+	l.flush(pos, true)
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	*r.n += n
+	return n, err
+}
+
+const magic = "AC01"
+
+// MarshalBinary encodes the compiled automaton.
+func (a *Automaton) MarshalBinary() ([]byte, error) {
+	var buf []byte
+	putInt := func(v int) {
+		var b [binary.MaxVarintLen64]byte
+		buf = append(buf, b[:binary.PutVarint(b[:], int64(v))]...)
+	}
+	buf = append(buf, magic...)
+	putInt(len(a.patterns))
+	for _, p := range a.patterns {
+		putInt(len(p))
+		buf = append(buf, p...)
+	}
+	if a.sets == nil {
+		putInt(-1)
+	} else {
+		putInt(len(a.sets))
+		for _, v := range a.sets {
+			putInt(v)
+		}
+	}
+	putInt(len(a.nodes))
+	for _, n := range a.nodes {
+		putInt(int(n.begin))
+		putInt(int(n.end))
+		putInt(int(n.fail))
+		putInt(int(n.out))
+		putInt(int(n.dict))
+	}
+	putInt(len(a.edges))
+	for _, e := range a.edges {
+		buf = append(buf, e.label)
+		putInt(int(e.to))
+	}
+	for _, v := range a.same {
+		putInt(int(v))
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes an automaton encoded by MarshalBinary.
+//
+//nolint:gocyclo
+func (a *Automaton) UnmarshalBinary(data []byte) error {
+	errInvalid := errors.New("ahocorasick: invalid data")
+	if len(data) < len(magic) || string(data[:len(magic)]) != magic {
+		return errInvalid
+	}
+	r := bytes.NewReader(data[len(magic):])
+	var err error
+	getInt := func() int {
+		if err != nil {
+			return 0
+		}
+		var v int64
+		v, err = binary.ReadVarint(r)
+		return int(v)
+	}
+	// count reads a count of the items, every item takes a byte at least.
+	count := func() int {
+		n := getInt()
+		if err == nil && (n < 0 || n > len(data)) {
+			err = errInvalid
+		}
+		if err != nil {
+			return 0
+		}
+		return n
+	}
+	var ret Automaton
+	ret.patterns = make([]string, count())
+	for i := range ret.patterns {
+		n := count()
+		if err != nil {
+			return err
+		}
+		b := make([]byte, n)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return errInvalid
+		}
+		ret.patterns[i] = string(b)
+		if n > ret.maxLen {
+			ret.maxLen = n
+		}
+	}
+	if n := getInt(); n >= 0 && err == nil {
+		if n > len(data) {
+			return errInvalid
+		}
+		ret.sets = make([]int, n)
+		for i := range ret.sets {
+			ret.sets[i] = getInt()
+		}
+	}
+	ret.nodes = make([]node, count())
+	for i := range ret.nodes {
+		ret.nodes[i] = node{
+			begin: int32(getInt()),
+			end:   int32(getInt()),
+			fail:  int32(getInt()),
+			out:   int32(getInt()),
+			dict:  int32(getInt()),
+		}
+	}
+	ret.edges = make([]edge, count())
+	for i := range ret.edges {
+		var label byte
+		if err == nil {
+			label, err = r.ReadByte()
+		}
+		ret.edges[i] = edge{label: label, to: int32(getInt())}
+	}
+	ret.same = make([]int32, len(ret.patterns))
+	for i := range ret.same {
+		ret.same[i] = int32(getInt())
+	}
+	if err != nil {
+		return errInvalid
+	}
+	if !ret.valid() {
+		return errInvalid
+	}
+	for _, e := range ret.nodeEdges(0) {
+		ret.root[e.label] = e.to
+	}
+	*a = ret
+	return nil
+}
+
+// valid returns true if the indexes of the automaton are in the ranges,
+// the edges form a tree and the fail links and the output chains go back, so the scans terminate.
+//
+//nolint:gocyclo
+func (a *Automaton) valid() bool {
+	if len(a.nodes) == 0 {
+		return false
+	}
+	inNodes := func(v int32, none bool) bool {
+		return (none && v == -1) || (0 <= v && int(v) < len(a.nodes))
+	}
+	inPatterns := func(v int32) bool {
+		return v == -1 || (0 <= v && int(v) < len(a.patterns))
+	}
+	for _, n := range a.nodes {
+		if n.begin < 0 || n.begin > n.end || int(n.end) > len(a.edges) ||
+			!inNodes(n.fail, false) || !inPatterns(n.out) || !inNodes(n.dict, true) {
+			return false
+		}
+	}
+	for _, e := range a.edges {
+		if e.to <= 0 || int(e.to) >= len(a.nodes) {
+			return false
+		}
+	}
+	for i, v := range a.sets {
+		if v < 0 || v > len(a.patterns) || (i > 0 && v < a.sets[i-1]) {
+			return false
+		}
+	}
+	for i, v := range a.same {
+		if v != -1 && (int(v) <= i || !inPatterns(v)) {
+			return false
+		}
+	}
+	depth := make([]int, len(a.nodes))
+	for i := range depth {
+		depth[i] = -1
+	}
+	depth[0] = 0
+	queue := []int32{0}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range a.nodeEdges(n) {
+			if depth[e.to] >= 0 {
+				return false
+			}
+			depth[e.to] = depth[n] + 1
+			queue = append(queue, e.to)
+		}
+	}
+	for i, n := range a.nodes {
+		if depth[i] < 0 || (i > 0 && depth[n.fail] >= depth[i]) || (n.dict >= 0 && depth[n.dict] >= depth[i]) {
+			return false
+		}
+		for id := n.out; id >= 0; id = a.same[id] {
+			if len(a.patterns[id]) != depth[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,248 @@
+package ahocorasick
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+type stringSet []string
+
+func (s stringSet) Items() []string {
+	return s
+}
+
+// naiveOverlapping returns the occurrences in the order of Overlapping by scanning the patterns at each position.
+func naiveOverlapping(patterns []string, text string) []Match {
+	var ret []Match
+	for end := 1; end <= len(text); end++ {
+		var ms []Match
+		for id, p := range patterns {
+			if p != "" && strings.HasSuffix(text[:end], p) {
+				ms = append(ms, Match{Pattern: id, Start: end - len(p), End: end})
+			}
+		}
+		sort.SliceStable(ms, func(i, j int) bool { return ms[i].Start < ms[j].Start })
+		ret = append(ret, ms...)
+	}
+	return ret
+}
+
+// naiveLeftmostFirst returns the leftmost-first occurrences by scanning the patterns at each position.
+func naiveLeftmostFirst(patterns []string, text string) []Match {
+	var ret []Match
+	for start := 0; start < len(text); {
+		found := false
+		for id, p := range patterns {
+			if p != "" && strings.HasPrefix(text[start:], p) {
+				ret = append(ret, Match{Pattern: id, Start: start, End: start + len(p)})
+				start += len(p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			start++
+		}
+	}
+	return ret
+}
+
+func randomPatterns(r *rand.Rand) []string {
+	ret := make([]string, r.Intn(8))
+	for i := range ret {
+		b := make([]byte, r.Intn(4))
+		for j := range b {
+			b[j] = "abc"[r.Intn(3)]
+		}
+		ret[i] = string(b)
+	}
+	return ret
+}
+
+func randomText(r *rand.Rand) string {
+	b := make([]byte, r.Intn(30))
+	for i := range b {
+		b[i] = "abcd"[r.Intn(4)]
+	}
+	return string(b)
+}
+
+func collect(each func(fn func(m Match) bool)) []Match {
+	var ret []Match
+	each(func(m Match) bool {
+		ret = append(ret, m)
+		return true
+	})
+	return ret
+}
+
+func TestAutomaton(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		patterns, text := randomPatterns(r), randomText(r)
+		a := New(patterns)
+		want := naiveOverlapping(patterns, text)
+		if got := collect(func(fn func(m Match) bool) { a.Overlapping([]byte(text), fn) }); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: Overlapping(%q) = %v, want %v", patterns, text, got, want)
+		}
+		if got := collect(func(fn func(m Match) bool) { a.OverlappingString(text, fn) }); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: OverlappingString(%q) = %v, want %v", patterns, text, got, want)
+		}
+		got := collect(func(fn func(m Match) bool) {
+			if err := a.OverlappingReader(iotest.OneByteReader(strings.NewReader(text)), fn); err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+		})
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: OverlappingReader(%q) = %v, want %v", patterns, text, got, want)
+		}
+		want = naiveLeftmostFirst(patterns, text)
+		if got := a.FindAll([]byte(text)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: FindAll(%q) = %v, want %v", patterns, text, got, want)
+		}
+		got = collect(func(fn func(m Match) bool) {
+			if err := a.LeftmostFirstReader(iotest.HalfReader(strings.NewReader(text)), fn); err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+		})
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: LeftmostFirstReader(%q) = %v, want %v", patterns, text, got, want)
+		}
+		m, ok := a.Find([]byte(text))
+		if ok != (len(want) > 0) || (ok && m != want[0]) {
+			t.Fatalf("%q: Find(%q) = %v, %v, want %v", patterns, text, m, ok, want)
+		}
+	}
+}
+
+func TestAutomaton_Overlapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		text     string
+	}{
+		{name: "empty text", patterns: []string{"a", "b"}, text: ""},
+		{name: "no patterns", patterns: nil, text: "abc"},
+		{name: "empty pattern", patterns: []string{"", "b"}, text: "abc"},
+		{name: "he she his hers", patterns: []string{"he", "she", "his", "hers"}, text: "ushers"},
+		{name: "suffixes", patterns: []string{"abcd", "bcd", "cd", "d", "bc"}, text: "xabcdabcd"},
+		{name: "duplicates", patterns: []string{"ab", "b", "ab"}, text: "abab"},
+		{name: "multibyte", patterns: []string{"寿司", "司", "すし"}, text: "回転寿司とすし"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := naiveOverlapping(tt.patterns, tt.text)
+			if got := collect(func(fn func(m Match) bool) { New(tt.patterns).Overlapping([]byte(tt.text), fn) }); !reflect.DeepEqual(got, want) {
+				t.Errorf("Overlapping() = %v, want %v", got, want)
+			}
+			if got := collect(func(fn func(m Match) bool) { New(tt.patterns).OverlappingString(tt.text, fn) }); !reflect.DeepEqual(got, want) {
+				t.Errorf("OverlappingString() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestAutomaton_stop(t *testing.T) {
+	a := New([]string{"a", "ab", "b"})
+	var got []Match
+	a.Overlapping([]byte("abab"), func(m Match) bool {
+		got = append(got, m)
+		return len(got) < 2
+	})
+	if want := []Match{{Pattern: 0, Start: 0, End: 1}, {Pattern: 1, Start: 0, End: 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Overlapping() = %v, want %v", got, want)
+	}
+	got = nil
+	a.OverlappingString("abab", func(m Match) bool {
+		got = append(got, m)
+		return len(got) < 2
+	})
+	if want := []Match{{Pattern: 0, Start: 0, End: 1}, {Pattern: 1, Start: 0, End: 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OverlappingString() = %v, want %v", got, want)
+	}
+	got = nil
+	a.LeftmostFirst([]byte("abab"), func(m Match) bool {
+		got = append(got, m)
+		return false
+	})
+	if want := []Match{{Pattern: 0, Start: 0, End: 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LeftmostFirst() = %v, want %v", got, want)
+	}
+}
+
+func TestNewFromSets(t *testing.T) {
+	a := NewFromSets(stringSet{"foo", "bar"}, stringSet(nil), stringSet{"bar", "baz"})
+	if got, want := a.Len(), 4; got != want {
+		t.Fatalf("Len() = %v, want %v", got, want)
+	}
+	var got []int
+	a.Overlapping([]byte("foobarbaz"), func(m Match) bool {
+		got = append(got, a.SetOf(m.Pattern), m.Pattern)
+		return true
+	})
+	if want := []int{0, 0, 0, 1, 2, 2, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sets and patterns = %v, want %v", got, want)
+	}
+	if got, want := a.Pattern(3), "baz"; got != want {
+		t.Errorf("Pattern(3) = %v, want %v", got, want)
+	}
+	if got := New([]string{"a"}).SetOf(0); got != -1 {
+		t.Errorf("SetOf() = %v, want -1", got)
+	}
+}
+
+func TestAutomaton_MarshalBinary(t *testing.T) {
+	a := NewFromSets(stringSet{"he", "she", "his", "hers"}, stringSet{"he", ""})
+	data, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	var b Automaton
+	if err := b.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if !reflect.DeepEqual(*a, b) {
+		t.Errorf("UnmarshalBinary() = %+v, want %+v", b, *a)
+	}
+	text := []byte("ushers and his hershey")
+	if got, want := b.FindAll(text), a.FindAll(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAll() = %v, want %v", got, want)
+	}
+	// Corrupted data must not build an automaton which fails to scan.
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		c := append([]byte(nil), data...)
+		c[len(magic)+r.Intn(len(c)-len(magic))] = byte(r.Intn(256))
+		var x Automaton
+		if err := x.UnmarshalBinary(c[:len(magic)+r.Intn(len(c)-len(magic)+1)]); err == nil {
+			x.FindAll(text)
+		}
+	}
+	if err := b.UnmarshalBinary([]byte("XX")); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func BenchmarkAutomaton_Overlapping(b *testing.B) {
+	patterns := make([]string, 100)
+	r := rand.New(rand.NewSource(1))
+	for i := range patterns {
+		p := make([]byte, 8)
+		for j := range p {
+			p[j] = byte('a' + r.Intn(26))
+		}
+		patterns[i] = string(p)
+	}
+	a := New(patterns)
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 1<<10)
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Overlapping(text, func(m Match) bool { return true })
+	}
+}
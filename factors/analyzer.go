@@ -9,22 +9,55 @@ import (
 const charClassLimit = 100
 
 // Analyzer is a regexp necessary factor analyzer.
-type Analyzer struct{}
+// An analyzer is never modified after it is created, so it is safe for concurrent use.
+type Analyzer struct {
+	cache       *Cache
+	parallelism int
+	observer    Observer
+	tokenizer   Tokenizer
+}
+
+// Option represents an option of the analyzer.
+type Option func(a *Analyzer)
+
+// WithCache sets a cache shared among analyses, e.g. to analyze a batch of patterns.
+// Without a cache, an analyzer does not memoize the factors of sub expressions.
+func WithCache(c *Cache) Option {
+	return func(a *Analyzer) {
+		a.cache = c
+	}
+}
 
 // NewAnalyzer creates new analyzer.
-func NewAnalyzer() *Analyzer {
-	return &Analyzer{}
+func NewAnalyzer(opts ...Option) *Analyzer {
+	ret := &Analyzer{}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	return ret
 }
 
 // Factor returns necessary factors for a given regexp.
+// With a cache (WithCache), factors of structurally identical sub expressions are computed only once.
 func (a Analyzer) Factor(re *syntax.Regexp) Factor {
-	root := analyze(re, false)
+	root := a.newMemo().analyze(re, false)
 	return root.Factor
 }
 
 // Parse parses necessary factors for a given regexp, and returns a it's parse tree.
 func (a Analyzer) Parse(re *syntax.Regexp) *Node {
-	return analyze(re, true)
+	return a.newMemo().analyze(re, true)
+}
+
+// newMemo returns a memo with the cache of the analyzer.
+// Without a cache and an observer, it returns nil, i.e. the analysis recurses directly without memoization.
+func (a Analyzer) newMemo() *memo {
+	if a.cache == nil && a.observer == nil {
+		return nil
+	}
+	m := newMemo(a.cache)
+	m.observer = a.observer
+	return m
 }
 
 // DebugParse parses necessary factors for a given regexp, and writes a it's parse tree in dot format.
@@ -47,11 +80,12 @@ func (a Analyzer) DebugParse(w io.Writer, re *syntax.Regexp) Factor {
 //  	Cap      int        // capturing index, for OpCapture
 //  	Name     string     // capturing name, for OpCapture
 // }
-//nolint:gocyclo
 func analyze(re *syntax.Regexp, tree bool) *Node {
-	if re == nil {
-		return nil
-	}
+	return (*memo)(nil).analyze(re, tree)
+}
+
+//nolint:gocyclo
+func (m *memo) analyzeOp(re *syntax.Regexp, tree bool) *Node {
 	switch re.Op {
 	case syntax.OpNoMatch:
 		return &Node{
@@ -112,7 +146,7 @@ func analyze(re *syntax.Regexp, tree bool) *Node {
 			Regexp: re,
 		}
 	case syntax.OpCapture:
-		n0 := analyze(re.Sub[0], tree)
+		n0 := m.analyze(re.Sub[0], tree)
 		n := &Node{
 			Factor: n0.Factor,
 			Regexp: re,
@@ -129,7 +163,7 @@ func analyze(re *syntax.Regexp, tree bool) *Node {
 			}
 		}
 		if len(re.Sub) == 1 {
-			n0 := analyze(re.Sub[0], tree)
+			n0 := m.analyze(re.Sub[0], tree)
 			n := &Node{
 				Factor: n0.Factor,
 				Regexp: re,
@@ -139,17 +173,17 @@ func analyze(re *syntax.Regexp, tree bool) *Node {
 			}
 			return n
 		}
-		n0, n1 := analyze(re.Sub[0], tree), analyze(re.Sub[1], tree)
+		n0, n1 := m.analyze(re.Sub[0], tree), m.analyze(re.Sub[1], tree)
 		n := &Node{
-			Factor: Concatenate(n0.Factor, n1.Factor),
 			Regexp: re,
 		}
+		n.Factor = n.concatenate(n0.Factor, n1.Factor, 1, tree)
 		if tree {
 			n.Child = append(n.Child, n0, n1)
 		}
 		for i := 2; i < len(re.Sub); i++ {
-			ni := analyze(re.Sub[i], tree)
-			n.Factor = Concatenate(n.Factor, ni.Factor)
+			ni := m.analyze(re.Sub[i], tree)
+			n.Factor = n.concatenate(n.Factor, ni.Factor, i, tree)
 			if tree {
 				n.Child = append(n.Child, ni)
 			}
@@ -163,7 +197,7 @@ func analyze(re *syntax.Regexp, tree bool) *Node {
 			}
 		}
 		if len(re.Sub) == 1 {
-			n0 := analyze(re.Sub[0], tree)
+			n0 := m.analyze(re.Sub[0], tree)
 			return &Node{
 				Factor: n0.Factor,
 				Regexp: re,
@@ -179,14 +213,14 @@ func analyze(re *syntax.Regexp, tree bool) *Node {
 				}
 			}
 		}
-		n0 := analyze(re.Sub[0], tree)
+		n0 := m.analyze(re.Sub[0], tree)
 		if !tree && n0.Factor.Infinite() {
 			return &Node{
 				Factor: NewFactorInfinite(),
 				Regexp: re,
 			}
 		}
-		n1 := analyze(re.Sub[1], tree)
+		n1 := m.analyze(re.Sub[1], tree)
 		if !tree && n1.Factor.Infinite() {
 			return &Node{
 				Factor: NewFactorInfinite(),
@@ -201,7 +235,7 @@ func analyze(re *syntax.Regexp, tree bool) *Node {
 			n.Child = append(n.Child, n0, n1)
 		}
 		for i := 2; i < len(re.Sub); i++ {
-			ni := analyze(re.Sub[i], tree)
+			ni := m.analyze(re.Sub[i], tree)
 			if !tree && ni.Factor.Infinite() {
 				return &Node{
 					Factor: NewFactorInfinite(),
@@ -220,40 +254,42 @@ func analyze(re *syntax.Regexp, tree bool) *Node {
 			Regexp: re,
 		}
 		if tree {
-			n0 := analyze(re.Sub[0], true)
+			n0 := m.analyze(re.Sub[0], true)
 			n.Child = append(n.Child, n0)
 		}
 		return n
 	case syntax.OpStar:
 		n := &Node{Factor: NewFactorInfinite(), Regexp: re}
 		if tree {
-			n.Child = append(n.Child, analyze(re.Sub[0], true))
+			n.Child = append(n.Child, m.analyze(re.Sub[0], true))
 		}
 		return n
 	case syntax.OpRepeat:
+		n0 := m.analyze(re.Sub[0], tree)
 		if re.Min == 0 {
-			n0 := analyze(re.Sub[0], tree)
 			n := &Node{Factor: NewFactorInfinite(), Regexp: re}
 			if tree {
 				n.Child = append(n.Child, n0)
 			}
 			return n
 		}
-		fallthrough
-	case syntax.OpPlus:
-		n0 := analyze(re.Sub[0], tree)
-		if !n0.Factor.Exact.infinite {
-			n0.Factor.Exact.Clear()
-			n0.Factor.Exact.infinite = true
-		}
-		n := &Node{
-			Factor: n0.Factor,
-			Regexp: re,
-		}
-		if tree {
-			n.Child = append(n.Child, n0)
+		if re.Max >= re.Min {
+			if n := repeatSize(n0.Factor.Exact, re.Max); n >= 0 && n <= repeatExactLimit {
+				n := &Node{
+					Regexp: re,
+				}
+				if tree {
+					n.Factor = repeat(n0.Factor, re.Min, re.Max, &n.Choices)
+					n.Child = append(n.Child, n0)
+				} else {
+					n.Factor = Repeat(n0.Factor, re.Min, re.Max)
+				}
+				return n
+			}
 		}
-		return n
+		return plusNode(re, n0, tree)
+	case syntax.OpPlus:
+		return plusNode(re, m.analyze(re.Sub[0], tree), tree)
 	case syntax.OpCharClass:
 		if len(re.Rune) == 0 {
 			return &Node{
@@ -294,3 +330,29 @@ func analyze(re *syntax.Regexp, tree bool) *Node {
 		Regexp: re,
 	}
 }
+
+// plusNode returns the node of the repetition re of at least once, given the node of the sub expression.
+func plusNode(re *syntax.Regexp, n0 *Node, tree bool) *Node {
+	if !n0.Factor.Exact.infinite {
+		n0.Factor.Exact.Clear()
+		n0.Factor.Exact.infinite = true
+	}
+	n := &Node{
+		Factor: n0.Factor,
+		Regexp: re,
+	}
+	if tree {
+		n.Child = append(n.Child, n0)
+	}
+	return n
+}
+
+// concatenate returns the factor of a・b, and records the choices of the sets as the step in the parse tree.
+func (n *Node) concatenate(a, b Factor, step int, tree bool) Factor {
+	if !tree {
+		return Concatenate(a, b)
+	}
+	ret := concatenate(a, b, &n.Choices)
+	setStep(&n.Choices, step)
+	return ret
+}
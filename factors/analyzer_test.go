@@ -41,31 +41,19 @@ func Test_analyze(t *testing.T) {
 			},
 			want: Factor{
 				Exact: Set{
-					items: stringSet{
-						"a": {},
-						"b": {},
-					},
+					items:      stringSet{"a", "b"},
 					minimumLen: 1,
 				},
 				Prefix: Set{
-					items: stringSet{
-						"a": {},
-						"b": {},
-					},
+					items:      stringSet{"a", "b"},
 					minimumLen: 1,
 				},
 				Suffix: Set{
-					items: stringSet{
-						"a": {},
-						"b": {},
-					},
+					items:      stringSet{"a", "b"},
 					minimumLen: 1,
 				},
 				Fragment: Set{
-					items: stringSet{
-						"a": {},
-						"b": {},
-					},
+					items:      stringSet{"a", "b"},
 					minimumLen: 1,
 				},
 			},
@@ -78,27 +66,19 @@ func Test_analyze(t *testing.T) {
 			},
 			want: Factor{
 				Exact: Set{
-					items: stringSet{
-						"ab": {},
-					},
+					items:      stringSet{"ab"},
 					minimumLen: 2,
 				},
 				Prefix: Set{
-					items: stringSet{
-						"ab": {},
-					},
+					items:      stringSet{"ab"},
 					minimumLen: 2,
 				},
 				Suffix: Set{
-					items: stringSet{
-						"ab": {},
-					},
+					items:      stringSet{"ab"},
 					minimumLen: 2,
 				},
 				Fragment: Set{
-					items: stringSet{
-						"ab": {},
-					},
+					items:      stringSet{"ab"},
 					minimumLen: 2,
 				},
 			},
@@ -114,21 +94,15 @@ func Test_analyze(t *testing.T) {
 					infinite: true,
 				},
 				Prefix: Set{
-					items: stringSet{
-						"a": {},
-					},
+					items:      stringSet{"a"},
 					minimumLen: 1,
 				},
 				Suffix: Set{
-					items: stringSet{
-						"a": {},
-					},
+					items:      stringSet{"a"},
 					minimumLen: 1,
 				},
 				Fragment: Set{
-					items: stringSet{
-						"a": {},
-					},
+					items:      stringSet{"a"},
 					minimumLen: 1,
 				},
 			},
@@ -144,18 +118,14 @@ func Test_analyze(t *testing.T) {
 					infinite: true,
 				},
 				Prefix: Set{
-					items: stringSet{
-						"a": {},
-					},
+					items:      stringSet{"a"},
 					minimumLen: 1,
 				},
 				Suffix: Set{
 					infinite: true,
 				},
 				Fragment: Set{
-					items: stringSet{
-						"a": {},
-					},
+					items:      stringSet{"a"},
 					minimumLen: 1,
 				},
 			},
@@ -184,20 +154,14 @@ func Test_analyze(t *testing.T) {
 					infinite: true,
 				},
 				Prefix: Set{
-					items: stringSet{
-						"AGATA": {},
-						"GAATA": {},
-					},
+					items:      stringSet{"AGATA", "GAATA"},
 					minimumLen: 5,
 				},
 				Suffix: Set{
 					infinite: true,
 				},
 				Fragment: Set{
-					items: stringSet{
-						"AGATA": {},
-						"GAATA": {},
-					},
+					items:      stringSet{"AGATA", "GAATA"},
 					minimumLen: 5,
 				},
 			},
@@ -216,17 +180,11 @@ func Test_analyze(t *testing.T) {
 					infinite: true,
 				},
 				Suffix: Set{
-					items: stringSet{
-						"TA": {},
-						"AG": {},
-					},
+					items:      stringSet{"AG", "TA"},
 					minimumLen: 2,
 				},
 				Fragment: Set{
-					items: stringSet{
-						"TA": {},
-						"AG": {},
-					},
+					items:      stringSet{"AG", "TA"},
 					minimumLen: 2,
 				},
 			},
@@ -240,3 +198,92 @@ func Test_analyze(t *testing.T) {
 		})
 	}
 }
+
+func Test_analyze_repeat(t *testing.T) {
+	tests := []struct {
+		re   string
+		want Factor
+	}{
+		{
+			re: `a{2,3}`,
+			want: Factor{
+				Exact:    NewSet("aa", "aaa"),
+				Prefix:   NewSet("aa"),
+				Suffix:   NewSet("aa"),
+				Fragment: NewSet("aa"),
+			},
+		},
+		{
+			re: `[ab]{2}`,
+			want: Factor{
+				Exact:    NewSet("aa", "ab", "ba", "bb"),
+				Prefix:   NewSet("aa", "ab", "ba", "bb"),
+				Suffix:   NewSet("aa", "ab", "ba", "bb"),
+				Fragment: NewSet("aa", "ab", "ba", "bb"),
+			},
+		},
+		{
+			// too many exact strings, same as `\d+`.
+			re:   `\d{4}`,
+			want: analyze(syntaxRegexp(t, `\d+`), false).Factor,
+		},
+		{
+			// the empty string is a necessary factor of the repeated empty width assertion.
+			re: `(?:$){2}|x`,
+			want: Factor{
+				Exact:    NewSet("", "x"),
+				Prefix:   NewSet("", "x"),
+				Suffix:   NewSet("", "x"),
+				Fragment: NewSet("", "x"),
+			},
+		},
+		{
+			re: `(?:a|){2}`,
+			want: Factor{
+				Exact:    NewSet("", "a", "aa"),
+				Prefix:   NewSet(""),
+				Suffix:   NewSet(""),
+				Fragment: NewSet(""),
+			},
+		},
+		{
+			re: `a{2,}`,
+			want: Factor{
+				Exact:    Set{infinite: true},
+				Prefix:   NewSet("a"),
+				Suffix:   NewSet("a"),
+				Fragment: NewSet("a"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.re, func(t *testing.T) {
+			if got := analyze(syntaxRegexp(t, tt.re), false); !reflect.DeepEqual(got.Factor, tt.want) {
+				t.Errorf("analyze() = %v, want %v", got.Factor, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkAnalyzer_Factor(b *testing.B) {
+	patterns := []string{
+		`(GET|POST|PUT|DELETE) /api/v[12]/(users|items|orders)/\d+`,
+		`(?:25[0-5]|2[0-4]\d|1?\d?\d)(?:\.(?:25[0-5]|2[0-4]\d|1?\d?\d)){3}`,
+		`[a-z0-9._]+@example\.(com|org|net)`,
+		`error: (timeout|connection refused|no route to host)`,
+		`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}`,
+	}
+	for _, p := range patterns {
+		re, err := syntax.Parse(p, syntax.Perl)
+		if err != nil {
+			b.Fatalf("syntax parse error: %v", err)
+		}
+		a := NewAnalyzer()
+		b.Run(p, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				a.Factor(re)
+			}
+		})
+	}
+}
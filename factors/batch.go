@@ -0,0 +1,78 @@
+package factors
+
+import (
+	"context"
+	"regexp/syntax"
+	"runtime"
+	"sync"
+)
+
+// Result represents a result of the analysis of a pattern.
+type Result struct {
+	Pattern string
+	Factor  Factor
+	Err     error
+}
+
+// WithParallelism sets the maximum number of patterns analyzed concurrently by AnalyzeAll.
+// It defaults to GOMAXPROCS.
+func WithParallelism(n int) Option {
+	return func(a *Analyzer) {
+		a.parallelism = n
+	}
+}
+
+// AnalyzeAll parses (with syntax.Perl flags) and analyzes patterns concurrently,
+// and returns the results in the order of the patterns.
+// An error of a pattern is reported in its result and does not abort the others.
+// If the context is done, the patterns not analyzed yet have the error of the context.
+func (a Analyzer) AnalyzeAll(ctx context.Context, patterns []string) []Result {
+	ret := make([]Result, len(patterns))
+	n := a.parallelism
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	if n > len(patterns) {
+		n = len(patterns)
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := ctx.Err(); err != nil {
+					ret[j] = Result{Pattern: patterns[j], Err: err}
+					continue
+				}
+				ret[j] = a.analyzePattern(patterns[j])
+			}
+		}()
+	}
+loop:
+	for i := range patterns {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			for j := i; j < len(patterns); j++ {
+				ret[j] = Result{Pattern: patterns[j], Err: ctx.Err()}
+			}
+			break loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return ret
+}
+
+func (a Analyzer) analyzePattern(pattern string) Result {
+	ret := Result{Pattern: pattern}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		ret.Err = err
+		return ret
+	}
+	ret.Factor = a.Factor(re)
+	return ret
+}
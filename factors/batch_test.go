@@ -0,0 +1,55 @@
+package factors
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzer_AnalyzeAll(t *testing.T) {
+	var patterns []string
+	for i := 0; i < 200; i++ {
+		patterns = append(patterns, fmt.Sprintf(`(GET|POST) /api/v%d/(users|items)/\d+`, i))
+		if i%50 == 0 {
+			patterns = append(patterns, `(unclosed`)
+		}
+	}
+	for _, a := range []*Analyzer{
+		NewAnalyzer(),
+		NewAnalyzer(WithParallelism(3), WithCache(NewCache())),
+	} {
+		got := a.AnalyzeAll(context.Background(), patterns)
+		if len(got) != len(patterns) {
+			t.Fatalf("len(AnalyzeAll()) = %d, want %d", len(got), len(patterns))
+		}
+		for i, r := range got {
+			if r.Pattern != patterns[i] {
+				t.Fatalf("result[%d].Pattern = %v, want %v", i, r.Pattern, patterns[i])
+			}
+			if r.Pattern == `(unclosed` {
+				if r.Err == nil {
+					t.Errorf("result[%d].Err = nil, want a parse error", i)
+				}
+				continue
+			}
+			if r.Err != nil {
+				t.Fatalf("result[%d].Err = %v", i, r.Err)
+			}
+			if want := analyze(syntaxRegexp(t, r.Pattern), false).Factor; !reflect.DeepEqual(r.Factor, want) {
+				t.Errorf("result[%d].Factor = %v, want %v", i, r.Factor, want)
+			}
+		}
+	}
+}
+
+func TestAnalyzer_AnalyzeAll_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got := NewAnalyzer().AnalyzeAll(ctx, []string{`a`, `b`, `c`})
+	for i, r := range got {
+		if r.Err != context.Canceled {
+			t.Errorf("result[%d].Err = %v, want %v", i, r.Err, context.Canceled)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package factors
+
+import (
+	"errors"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	dfaInstLimit  = 1000
+	dfaStateLimit = 10000
+)
+
+// ErrTooLarge is returned when a pattern exceeds the size limit of the DFA construction.
+var ErrTooLarge = errors.New("factors: pattern too large for the DFA construction")
+
+// runeRange represents a closed interval of runes.
+type runeRange struct {
+	lo, hi rune
+}
+
+// dfa is a deterministic automaton built from a regexp by the subset construction.
+// The alphabet is partitioned into rune ranges and every rune of a range behaves the same.
+// Empty-width assertions (^, $, \b, ...) are treated as always satisfied,
+// so the language of the automaton is a superset of the language of the regexp.
+type dfa struct {
+	classes []runeRange
+	start   int
+	accept  []bool
+	next    [][]int // next[state][class], -1 means the dead state.
+}
+
+func newDFA(re *syntax.Regexp) (*dfa, error) {
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	if len(prog.Inst) > dfaInstLimit {
+		return nil, ErrTooLarge
+	}
+	ret := &dfa{
+		classes: alphabet(prog),
+	}
+	index := map[string]int{}
+	var states [][]uint32
+	add := func(pcs []uint32) int {
+		key := stateKey(pcs)
+		if id, ok := index[key]; ok {
+			return id
+		}
+		id := len(states)
+		index[key] = id
+		states = append(states, pcs)
+		accept := false
+		for _, pc := range pcs {
+			if prog.Inst[pc].Op == syntax.InstMatch {
+				accept = true
+				break
+			}
+		}
+		ret.accept = append(ret.accept, accept)
+		ret.next = append(ret.next, nil)
+		return id
+	}
+	ret.start = add(closure(prog, []uint32{uint32(prog.Start)}))
+	for i := 0; i < len(states); i++ {
+		if len(states) > dfaStateLimit {
+			return nil, ErrTooLarge
+		}
+		ret.next[i] = make([]int, len(ret.classes))
+		for c, rr := range ret.classes {
+			var out []uint32
+			for _, pc := range states[i] {
+				inst := &prog.Inst[pc]
+				switch inst.Op {
+				case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+					if inst.MatchRune(rr.lo) {
+						out = append(out, inst.Out)
+					}
+				}
+			}
+			if len(out) == 0 {
+				ret.next[i][c] = -1
+				continue
+			}
+			ret.next[i][c] = add(closure(prog, out))
+		}
+	}
+	return ret, nil
+}
+
+// closure returns the sorted pcs of the consuming and the matching instructions
+// reachable from the given pcs without consuming any rune.
+func closure(prog *syntax.Prog, pcs []uint32) []uint32 {
+	visited := map[uint32]bool{}
+	var ret []uint32
+	stack := append([]uint32(nil), pcs...)
+	for len(stack) > 0 {
+		pc := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[pc] {
+			continue
+		}
+		visited[pc] = true
+		inst := &prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			stack = append(stack, inst.Out, inst.Arg)
+		case syntax.InstCapture, syntax.InstNop, syntax.InstEmptyWidth:
+			stack = append(stack, inst.Out)
+		case syntax.InstMatch, syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			ret = append(ret, pc)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+func stateKey(pcs []uint32) string {
+	var b strings.Builder
+	for _, pc := range pcs {
+		b.WriteString(strconv.FormatUint(uint64(pc), 10))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// alphabet partitions the runes into ranges that no instruction of the program can distinguish.
+func alphabet(prog *syntax.Prog) []runeRange {
+	bounds := map[rune]struct{}{0: {}}
+	mark := func(lo, hi rune) {
+		bounds[lo] = struct{}{}
+		if hi < unicode.MaxRune {
+			bounds[hi+1] = struct{}{}
+		}
+	}
+	for i := range prog.Inst {
+		inst := &prog.Inst[i]
+		switch inst.Op {
+		case syntax.InstRune:
+			if len(inst.Rune) == 1 {
+				r0 := inst.Rune[0]
+				mark(r0, r0)
+				if syntax.Flags(inst.Arg)&syntax.FoldCase != 0 {
+					for r1 := unicode.SimpleFold(r0); r1 != r0; r1 = unicode.SimpleFold(r1) {
+						mark(r1, r1)
+					}
+				}
+				continue
+			}
+			for j := 0; j+1 < len(inst.Rune); j += 2 {
+				mark(inst.Rune[j], inst.Rune[j+1])
+			}
+		case syntax.InstRune1:
+			mark(inst.Rune[0], inst.Rune[0])
+		case syntax.InstRuneAnyNotNL:
+			mark('\n', '\n')
+		}
+	}
+	bs := make([]rune, 0, len(bounds))
+	for b := range bounds {
+		bs = append(bs, b)
+	}
+	sort.Slice(bs, func(i, j int) bool { return bs[i] < bs[j] })
+	ret := make([]runeRange, 0, len(bs))
+	for i, b := range bs {
+		hi := rune(unicode.MaxRune)
+		if i+1 < len(bs) {
+			hi = bs[i+1] - 1
+		}
+		ret = append(ret, runeRange{lo: b, hi: hi})
+	}
+	return ret
+}
+
+// live returns states from which an accepting state is reachable.
+func (d *dfa) live() []bool {
+	ret := make([]bool, len(d.next))
+	copy(ret, d.accept)
+	for changed := true; changed; {
+		changed = false
+		for s := range d.next {
+			if ret[s] {
+				continue
+			}
+			for _, t := range d.next[s] {
+				if t >= 0 && ret[t] {
+					ret[s] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// shortest returns one of the shortest accepted strings. It returns false if the language is empty.
+func (d *dfa) shortest() ([]rune, bool) {
+	type edge struct {
+		from, class int
+	}
+	parent := make([]edge, len(d.next))
+	visited := make([]bool, len(d.next))
+	visited[d.start] = true
+	queue := []int{d.start}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if d.accept[s] {
+			var ret []rune
+			for s != d.start {
+				e := parent[s]
+				ret = append(ret, d.classes[e.class].lo)
+				s = e.from
+			}
+			for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+				ret[i], ret[j] = ret[j], ret[i]
+			}
+			return ret, true
+		}
+		for c, t := range d.next[s] {
+			if t < 0 || visited[t] {
+				continue
+			}
+			visited[t] = true
+			parent[t] = edge{from: s, class: c}
+			queue = append(queue, t)
+		}
+	}
+	return nil, false
+}
+
+// necessary returns true if every accepted string contains w.
+// It searches an accepting state in the product of the DFA and the KMP automaton of w.
+//
+//nolint:gocyclo
+func (d *dfa) necessary(w []rune) bool {
+	if len(w) == 0 {
+		return true
+	}
+	fail := make([]int, len(w))
+	for i, j := 1, 0; i < len(w); i++ {
+		for j > 0 && w[i] != w[j] {
+			j = fail[j-1]
+		}
+		if w[i] == w[j] {
+			j++
+		}
+		fail[i] = j
+	}
+	step := func(j int, r rune) int {
+		for j > 0 && w[j] != r {
+			j = fail[j-1]
+		}
+		if w[j] == r {
+			j++
+		}
+		return j
+	}
+	var alpha []rune
+	seen := map[rune]bool{}
+	for _, r := range w {
+		if !seen[r] {
+			seen[r] = true
+			alpha = append(alpha, r)
+		}
+	}
+	type state struct {
+		s, j int
+	}
+	visited := map[state]bool{{d.start, 0}: true}
+	queue := []state{{d.start, 0}}
+	for len(queue) > 0 {
+		q := queue[0]
+		queue = queue[1:]
+		if d.accept[q.s] {
+			return false
+		}
+		for c, t := range d.next[q.s] {
+			if t < 0 {
+				continue
+			}
+			rr := d.classes[c]
+			var js []int
+			n := 0
+			for _, r := range alpha {
+				if rr.lo <= r && r <= rr.hi {
+					js = append(js, step(q.j, r))
+					n++
+				}
+			}
+			if int64(n) < int64(rr.hi)-int64(rr.lo)+1 {
+				js = append(js, 0) // a rune which does not appear in w.
+			}
+			for _, j := range js {
+				if j == len(w) {
+					continue
+				}
+				next := state{t, j}
+				if visited[next] {
+					continue
+				}
+				visited[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return true
+}
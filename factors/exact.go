@@ -0,0 +1,88 @@
+package factors
+
+import (
+	"regexp/syntax"
+	"strings"
+)
+
+// ExactResult represents a result of the exact necessary fragment analysis.
+type ExactResult struct {
+	// Factor is the result of the syntactic analysis.
+	Factor Factor
+	// Fragment is the set of the maximal strings up to k runes which every matched string contains.
+	// It is infinite (θ) if the pattern matches nothing.
+	Fragment Set
+	// Optimal is true if the syntactic fragments are as selective as the exact ones.
+	Optimal bool
+}
+
+// ExactFactor computes the true necessary fragments up to k runes of a given regexp
+// using a DFA built by the subset construction, and compares them with the result of Factor.
+// Empty-width assertions are treated as always satisfied, so the fragments are sound but may be
+// fewer than the fragments of the assertions aware language.
+// It returns ErrTooLarge if the pattern exceeds the size limit.
+func (a Analyzer) ExactFactor(re *syntax.Regexp, k int) (ExactResult, error) {
+	ret := ExactResult{
+		Factor: a.Factor(re),
+	}
+	d, err := newDFA(re)
+	if err != nil {
+		return ret, err
+	}
+	s, ok := d.shortest()
+	if !ok {
+		ret.Fragment.SetInfinite()
+		ret.Optimal = true
+		return ret, nil
+	}
+	// Every necessary fragment is a substring of the shortest matched string.
+	var found []string
+	for n := k; n > 0; n-- {
+		for i := 0; i+n <= len(s); i++ {
+			w := string(s[i : i+n])
+			if containedIn(w, found) {
+				continue
+			}
+			if d.necessary(s[i : i+n]) {
+				found = append(found, w)
+			}
+		}
+	}
+	ret.Fragment = NewSet(found...)
+	ret.Optimal = longest(found) <= selectivity(ret.Factor.Fragment)
+	return ret, nil
+}
+
+// containedIn returns true if w is a substring of one of the items.
+func containedIn(w string, items []string) bool {
+	for _, v := range items {
+		if strings.Contains(v, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func longest(items []string) int {
+	var ret int
+	for _, v := range items {
+		if len(v) > ret {
+			ret = len(v)
+		}
+	}
+	return ret
+}
+
+// selectivity returns the length of the shortest item of the set, 0 if the set is infinite.
+func selectivity(s Set) int {
+	if s.infinite || s.size() == 0 {
+		return 0
+	}
+	ret := -1
+	for _, v := range s.Items() {
+		if ret < 0 || len(v) < ret {
+			ret = len(v)
+		}
+	}
+	return ret
+}
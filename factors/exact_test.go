@@ -0,0 +1,94 @@
+package factors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzer_ExactFactor(t *testing.T) {
+	tests := []struct {
+		name     string
+		re       string
+		k        int
+		fragment Set
+		optimal  bool
+	}{
+		{
+			name:     "literal",
+			re:       `abc`,
+			k:        3,
+			fragment: NewSet("abc"),
+			optimal:  true,
+		},
+		{
+			name:     "limited by k",
+			re:       `abcdef`,
+			k:        2,
+			fragment: NewSet("ab", "bc", "cd", "de", "ef"),
+			optimal:  true,
+		},
+		{
+			name:     "common substring of alternatives",
+			re:       `xabcx|yabcy`,
+			k:        5,
+			fragment: NewSet("abc"),
+			optimal:  true,
+		},
+		{
+			name:     "bounded repeat",
+			re:       `(?:abcd){2,3}`,
+			k:        8,
+			fragment: NewSet("abcdabcd"),
+			// the exact expansion of the bounded repeat finds the fragment.
+			optimal: true,
+		},
+		{
+			name:     "unbounded repeat",
+			re:       `(?:abcd){2,}`,
+			k:        8,
+			fragment: NewSet("abcdabcd"),
+			optimal:  false,
+		},
+		{
+			name:     "any char",
+			re:       `a.c`,
+			k:        3,
+			fragment: NewSet("a", "c"),
+			optimal:  true,
+		},
+		{
+			name:     "fold case",
+			re:       `(?i)ab`,
+			k:        2,
+			fragment: NewSet(),
+			optimal:  true,
+		},
+		{
+			name:     "no match",
+			re:       `[^\x00-\x{10FFFF}]`,
+			k:        2,
+			fragment: Set{infinite: true},
+			optimal:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewAnalyzer().ExactFactor(syntaxRegexp(t, tt.re), tt.k)
+			if err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+			if !reflect.DeepEqual(got.Fragment, tt.fragment) {
+				t.Errorf("Fragment = %v, want %v", got.Fragment, tt.fragment)
+			}
+			if got.Optimal != tt.optimal {
+				t.Errorf("Optimal = %v, want %v", got.Optimal, tt.optimal)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_ExactFactor_TooLarge(t *testing.T) {
+	if _, err := NewAnalyzer().ExactFactor(syntaxRegexp(t, `(abcdefghij){200}`), 3); err != ErrTooLarge {
+		t.Errorf("want ErrTooLarge, got %v", err)
+	}
+}
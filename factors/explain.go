@@ -0,0 +1,83 @@
+package factors
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Choice represents a choice of a set among the candidates by BestSet.
+type Choice struct {
+	// Set is the name of the chosen set, i.e. Prefix, Suffix or Fragment.
+	Set string
+	// Step is the step of the node which makes the choice,
+	// e.g. the index of b of `a・b` in a concatenation, where a is the concatenation of the preceding sub expressions.
+	Step       int
+	Candidates []Candidate
+	// Winner is the index of the chosen candidate.
+	Winner int
+}
+
+// Candidate represents a candidate set of a choice with its scores.
+type Candidate struct {
+	// Label describes how the set is derived, e.g. a.Exact・b.Prefix.
+	Label string
+	Set   Set
+	// MinimumLen is the length of the shortest item, the longer is the better.
+	MinimumLen int
+	// Size is the number of the items, the fewer is the better if the minimum lengths are the same.
+	// It is -1 if the set is infinite.
+	Size int
+}
+
+// choose returns the best set of the candidates, and appends the choice to the trace if it is not nil.
+func choose(trace *[]Choice, name string, cs ...Candidate) Set {
+	winner := 0
+	for i := 1; i < len(cs); i++ {
+		if better(cs[i].Set, cs[winner].Set) {
+			winner = i
+		}
+	}
+	if trace != nil {
+		for i := range cs {
+			cs[i].MinimumLen = cs[i].Set.minimumLen
+			cs[i].Size = cs[i].Set.Len()
+		}
+		*trace = append(*trace, Choice{Set: name, Candidates: cs, Winner: winner})
+	}
+	return cs[winner].Set
+}
+
+// setStep sets the step of the choices without steps in the trace.
+func setStep(trace *[]Choice, step int) {
+	if trace == nil {
+		return
+	}
+	for i := len(*trace) - 1; i >= 0 && (*trace)[i].Step == 0; i-- {
+		(*trace)[i].Step = step
+	}
+}
+
+// Explain writes the factors of the node and its descendants with the choices of the sets, i.e. why each set is chosen.
+func (n *Node) Explain(w io.Writer) {
+	n.explain(w, 0)
+}
+
+func (n *Node) explain(w io.Writer, depth int) {
+	indent := strings.Repeat("  ", depth)
+	fmt.Fprintf(w, "%s%s %s\n", indent, Op(n.Regexp.Op), n.Regexp)
+	fmt.Fprintf(w, "%s  %s\n", indent, n.Factor)
+	for _, c := range n.Choices {
+		fmt.Fprintf(w, "%s  %s at step %d:\n", indent, c.Set, c.Step)
+		for i, v := range c.Candidates {
+			mark := " "
+			if i == c.Winner {
+				mark = ">"
+			}
+			fmt.Fprintf(w, "%s  %s %s = %s (min: %d, size: %d)\n", indent, mark, v.Label, abbr(v.Set.String()), v.MinimumLen, v.Size)
+		}
+	}
+	for _, c := range n.Child {
+		c.explain(w, depth+1)
+	}
+}
@@ -0,0 +1,116 @@
+package factors
+
+import (
+	"bytes"
+	"regexp/syntax"
+	"testing"
+)
+
+func TestAnalyzer_Parse_choices(t *testing.T) {
+	re, err := syntax.Parse(`abc(?:x|y)+def`, syntax.Perl)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	root := NewAnalyzer().Parse(re)
+	want := []struct {
+		set    string
+		step   int
+		winner string
+	}{
+		{set: "Prefix", step: 1, winner: "a.Exact・b.Prefix"},
+		{set: "Suffix", step: 1, winner: "b.Suffix"},
+		{set: "Fragment", step: 1, winner: "a.Suffix・b.Prefix"},
+		{set: "Prefix", step: 2, winner: "a.Prefix"},
+		{set: "Suffix", step: 2, winner: "a.Suffix・b.Exact"},
+		{set: "Fragment", step: 2, winner: "a.Suffix・b.Prefix"},
+	}
+	if len(root.Choices) != len(want) {
+		t.Fatalf("len(Choices) = %d, want %d", len(root.Choices), len(want))
+	}
+	for i, c := range root.Choices {
+		if c.Set != want[i].set || c.Step != want[i].step || c.Candidates[c.Winner].Label != want[i].winner {
+			t.Errorf("Choices[%d] = %s at step %d won by %s, want %s at step %d won by %s",
+				i, c.Set, c.Step, c.Candidates[c.Winner].Label, want[i].set, want[i].step, want[i].winner)
+		}
+		// The winners of the last step are the sets of the factor.
+		if got, want := c.Candidates[c.Winner].Set, setOf(root.Factor, c.Set); c.Step == 2 && !got.Equal(want) {
+			t.Errorf("Choices[%d] winner = %v, want %v", i, got, want)
+		}
+	}
+	fragment := root.Choices[2].Candidates
+	if fragment[1].MinimumLen != 1 || fragment[1].Size != 2 {
+		t.Errorf("b.Fragment scores = (%d, %d), want (1, 2)", fragment[1].MinimumLen, fragment[1].Size)
+	}
+	if root.Choices[1].Candidates[1].Size != -1 {
+		t.Errorf("a.Suffix・b.Exact size = %d, want -1", root.Choices[1].Candidates[1].Size)
+	}
+	for _, c := range root.Child {
+		if len(c.Choices) != 0 {
+			t.Errorf("%v has choices %v", c.Regexp, c.Choices)
+		}
+	}
+}
+
+func TestAnalyzer_Parse_repeatChoices(t *testing.T) {
+	re, err := syntax.Parse(`(?:ab){2,3}`, syntax.Perl)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	root := NewAnalyzer().Parse(re)
+	if len(root.Choices) != 3 || root.Choices[0].Step != 1 {
+		t.Errorf("Choices = %v, want 3 choices at step 1", root.Choices)
+	}
+}
+
+func TestNode_Explain(t *testing.T) {
+	re, err := syntax.Parse(`ab*c`, syntax.Perl)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	var b bytes.Buffer
+	NewAnalyzer().Parse(re).Explain(&b)
+	want := `・ ab*c
+  <exact:θ, prefix:{a}, suffix:{c}, fragment:{c}>
+  Prefix at step 1:
+  > a.Prefix = {a} (min: 1, size: 1)
+    a.Exact・b.Prefix = θ (min: 0, size: -1)
+  Suffix at step 1:
+    b.Suffix = θ (min: 0, size: -1)
+  > a.Suffix・b.Exact = θ (min: 0, size: -1)
+  Fragment at step 1:
+  > a.Fragment = {a} (min: 1, size: 1)
+    b.Fragment = θ (min: 0, size: -1)
+    a.Suffix・b.Prefix = θ (min: 0, size: -1)
+  Prefix at step 2:
+  > a.Prefix = {a} (min: 1, size: 1)
+    a.Exact・b.Prefix = θ (min: 0, size: -1)
+  Suffix at step 2:
+  > b.Suffix = {c} (min: 1, size: 1)
+    a.Suffix・b.Exact = θ (min: 0, size: -1)
+  Fragment at step 2:
+    a.Fragment = {a} (min: 1, size: 1)
+  > b.Fragment = {c} (min: 1, size: 1)
+    a.Suffix・b.Prefix = θ (min: 0, size: -1)
+  Literal a
+    <exact:{a}, prefix:{a}, suffix:{a}, fragment:{a}>
+  * b*
+    <exact:θ, prefix:θ, suffix:θ, fragment:θ>
+    Literal b
+      <exact:{b}, prefix:{b}, suffix:{b}, fragment:{b}>
+  Literal c
+    <exact:{c}, prefix:{c}, suffix:{c}, fragment:{c}>
+`
+	if got := b.String(); got != want {
+		t.Errorf("Explain() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func setOf(f Factor, name string) Set {
+	switch name {
+	case "Prefix":
+		return f.Prefix
+	case "Suffix":
+		return f.Suffix
+	}
+	return f.Fragment
+}
@@ -0,0 +1,394 @@
+package factors
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ExprOp is an operator of a boolean expression.
+type ExprOp int
+
+const (
+	// ExprRegexp is a regexp which matches a text if it matches somewhere in the text.
+	ExprRegexp ExprOp = iota
+	// ExprAnd requires all the sub expressions.
+	ExprAnd
+	// ExprOr requires one of the sub expressions.
+	ExprOr
+	// ExprNot requires the sub expression not to match.
+	ExprNot
+)
+
+// Expr represents a boolean expression over regexps, e.g. `re1 AND (re2 OR re3) AND NOT re4`.
+type Expr struct {
+	Op      ExprOp
+	Pattern string         // the pattern of ExprRegexp.
+	Regexp  *syntax.Regexp // the parsed pattern of ExprRegexp.
+	Sub     []*Expr
+}
+
+// ParseExpr parses a boolean expression over regexps.
+// The operators are AND, OR and NOT in the order of decreasing precedence NOT, AND and OR,
+// and parentheses group sub expressions.
+// A regexp is a run of characters except spaces, which does not begin with a parenthesis and
+// may contain balanced parentheses, e.g. `a(b|c)d`, or a double quoted (with Go escapes) or back quoted string
+// to include spaces and the other parentheses. Escaped parentheses and the ones in a character class are not counted.
+// The regexps are parsed with syntax.Perl flags.
+func ParseExpr(s string) (*Expr, error) {
+	p := exprParser{input: s}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	ret, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != exprEOF {
+		return nil, fmt.Errorf("unexpected %s at %d", p.tok, p.tok.pos)
+	}
+	return ret, nil
+}
+
+// String returns string representation of an expression.
+func (e *Expr) String() string {
+	switch e.Op {
+	case ExprRegexp:
+		if e.Pattern == "" || strings.IndexFunc(e.Pattern, func(r rune) bool {
+			return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"' || r == '`'
+		}) >= 0 || isExprKeyword(e.Pattern) {
+			return strconv.Quote(e.Pattern)
+		}
+		return e.Pattern
+	case ExprNot:
+		return "NOT " + e.Sub[0].operand()
+	}
+	sep := " AND "
+	if e.Op == ExprOr {
+		sep = " OR "
+	}
+	ss := make([]string, len(e.Sub))
+	for i, v := range e.Sub {
+		ss[i] = v.operand()
+	}
+	return strings.Join(ss, sep)
+}
+
+// operand returns string representation of the expression as an operand.
+func (e *Expr) operand() string {
+	if e.Op == ExprAnd || e.Op == ExprOr {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+// Eval evaluates the expression with the results of the regexps given by match.
+func (e *Expr) Eval(match func(re *Expr) bool) bool {
+	switch e.Op {
+	case ExprRegexp:
+		return match(e)
+	case ExprNot:
+		return !e.Sub[0].Eval(match)
+	case ExprAnd:
+		for _, v := range e.Sub {
+			if !v.Eval(match) {
+				return false
+			}
+		}
+		return true
+	case ExprOr:
+		for _, v := range e.Sub {
+			if v.Eval(match) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// Plan represents how to execute a boolean expression over regexps with a literal index.
+type Plan struct {
+	// Query is the literal requirement every text matching the expression satisfies,
+	// the executor looks up the candidate texts with it.
+	Query *Query
+	// Verify is the regexps which the executor verifies for the candidate texts,
+	// in the order of appearance without duplicates of patterns.
+	Verify []*Expr
+	// Decided is the regexps which match a text if and only if the text contains one of their literals,
+	// so the executor decides them by the literals without running the regexps.
+	Decided []*Expr
+}
+
+// Plan analyzes the regexps of a given expression and returns a plan to execute it.
+// The query combines the requirements of the regexps with AND/OR semantics.
+// A text matching NOT re may or may not contain the literals of re, so the query ignores NOT branches.
+func (a Analyzer) Plan(e *Expr) Plan {
+	var ret Plan
+	seen := map[string]bool{}
+	var walk func(e *Expr) *Query
+	walk = func(e *Expr) *Query {
+		switch e.Op {
+		case ExprRegexp:
+			f := a.Factor(e.Regexp)
+			if !seen[e.Pattern] {
+				seen[e.Pattern] = true
+				if decidedByLiterals(f, e.Regexp) {
+					ret.Decided = append(ret.Decided, e)
+				} else {
+					ret.Verify = append(ret.Verify, e)
+				}
+			}
+			return factorQuery(f)
+		case ExprNot:
+			walk(e.Sub[0])
+			return allQuery
+		case ExprAnd:
+			q := allQuery
+			for _, v := range e.Sub {
+				q = q.and(walk(v))
+			}
+			return q
+		case ExprOr:
+			q := noneQuery
+			for _, v := range e.Sub {
+				q = q.or(walk(v))
+			}
+			return q
+		}
+		return allQuery
+	}
+	ret.Query = walk(e)
+	return ret
+}
+
+// decidedByLiterals returns true if a text contains a match of the regexp if and only if
+// the text contains an item of the exact set of the factor.
+// A regexp which matches U+FFFD also matches an invalid UTF-8 sequence, which no item is, so it is not decided.
+func decidedByLiterals(f Factor, re *syntax.Regexp) bool {
+	return !f.Exact.infinite && f.Exact.size() > 0 && !hasEmptyWidth(re) && !hasRuneError(re)
+}
+
+// hasEmptyWidth returns true if the regexp has an empty width assertion.
+func hasEmptyWidth(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return true
+	}
+	for _, v := range re.Sub {
+		if hasEmptyWidth(v) {
+			return true
+		}
+	}
+	return false
+}
+
+type exprTokenKind int
+
+const (
+	exprEOF exprTokenKind = iota
+	exprLParen
+	exprRParen
+	exprAnd
+	exprOr
+	exprNot
+	exprPattern
+)
+
+type exprToken struct {
+	kind  exprTokenKind
+	pos   int
+	value string
+}
+
+func (t exprToken) String() string {
+	switch t.kind {
+	case exprEOF:
+		return "end of expression"
+	case exprPattern:
+		return strconv.Quote(t.value)
+	}
+	return t.value
+}
+
+func isExprKeyword(s string) bool {
+	return s == "AND" || s == "OR" || s == "NOT"
+}
+
+type exprParser struct {
+	input string
+	pos   int
+	tok   exprToken
+}
+
+// next reads the next token.
+func (p *exprParser) next() error {
+	for p.pos < len(p.input) && isExprSpace(p.input[p.pos]) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos == len(p.input) {
+		p.tok = exprToken{kind: exprEOF, pos: start}
+		return nil
+	}
+	switch c := p.input[p.pos]; c {
+	case '(':
+		p.pos++
+		p.tok = exprToken{kind: exprLParen, pos: start, value: "("}
+		return nil
+	case ')':
+		p.pos++
+		p.tok = exprToken{kind: exprRParen, pos: start, value: ")"}
+		return nil
+	case '"', '`':
+		return p.quoted(c)
+	}
+	p.pos = patternEnd(p.input, p.pos)
+	v := p.input[start:p.pos]
+	switch v {
+	case "AND":
+		p.tok = exprToken{kind: exprAnd, pos: start, value: v}
+	case "OR":
+		p.tok = exprToken{kind: exprOr, pos: start, value: v}
+	case "NOT":
+		p.tok = exprToken{kind: exprNot, pos: start, value: v}
+	default:
+		p.tok = exprToken{kind: exprPattern, pos: start, value: v}
+	}
+	return nil
+}
+
+// quoted reads a pattern quoted by q.
+func (p *exprParser) quoted(q byte) error {
+	start := p.pos
+	end := p.pos + 1
+	for end < len(p.input) && p.input[end] != q {
+		if q == '"' && p.input[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(p.input) {
+		return fmt.Errorf("unterminated quoted pattern at %d", start)
+	}
+	v, err := strconv.Unquote(p.input[start : end+1])
+	if err != nil {
+		return fmt.Errorf("invalid quoted pattern at %d: %v", start, err)
+	}
+	p.pos = end + 1
+	p.tok = exprToken{kind: exprPattern, pos: start, value: v}
+	return nil
+}
+
+// patternEnd returns the end of an unquoted pattern which begins at i, i.e. the first space
+// or the first closing parenthesis which does not close a parenthesis in the pattern.
+func patternEnd(s string, i int) int {
+	depth := 0
+	class := -1 // the start of the character class, if the scan is in one.
+	for ; i < len(s); i++ {
+		switch c := s[i]; {
+		case isExprSpace(c):
+			return i
+		case c == '\\':
+			i++
+		case class >= 0:
+			// ] just after [ or [^ is a literal.
+			if c == ']' && i > class+1 && (i > class+2 || s[class+1] != '^') {
+				class = -1
+			}
+		case c == '[':
+			class = i
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return len(s)
+}
+
+func isExprSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func (p *exprParser) parseOr() (*Expr, error) {
+	return p.parseBinary(exprOr, ExprOr, p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (*Expr, error) {
+	return p.parseBinary(exprAnd, ExprAnd, p.parseNot)
+}
+
+// parseBinary parses operands joined by the operator.
+func (p *exprParser) parseBinary(kind exprTokenKind, op ExprOp, operand func() (*Expr, error)) (*Expr, error) {
+	e, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != kind {
+		return e, nil
+	}
+	ret := &Expr{Op: op, Sub: []*Expr{e}}
+	for p.tok.kind == kind {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		e, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		ret.Sub = append(ret.Sub, e)
+	}
+	return ret, nil
+}
+
+func (p *exprParser) parseNot() (*Expr, error) {
+	if p.tok.kind != exprNot {
+		return p.parsePrimary()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	e, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{Op: ExprNot, Sub: []*Expr{e}}, nil
+}
+
+func (p *exprParser) parsePrimary() (*Expr, error) {
+	switch tok := p.tok; tok.kind {
+	case exprLParen:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != exprRParen {
+			return nil, fmt.Errorf("expected ) at %d, got %s", p.tok.pos, p.tok)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case exprPattern:
+		re, err := syntax.Parse(tok.value, syntax.Perl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern at %d: %v", tok.pos, err)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		return &Expr{Op: ExprRegexp, Pattern: tok.value, Regexp: re}, nil
+	default:
+		return nil, fmt.Errorf("expected a pattern at %d, got %s", tok.pos, tok)
+	}
+}
@@ -0,0 +1,176 @@
+package factors
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `abc`, want: `abc`},
+		{input: `re1 AND (re2 OR re3) AND NOT re4`, want: `re1 AND (re2 OR re3) AND NOT re4`},
+		{input: `a OR b AND c`, want: `a OR (b AND c)`},
+		{input: `NOT a AND b`, want: `NOT a AND b`},
+		{input: `NOT (a OR b)`, want: `NOT (a OR b)`},
+		{input: `((a))`, want: `a`},
+		{input: `"a b" OR ` + "`(x|y)z`", want: `"a b" OR "(x|y)z"`},
+		{input: `"AND" AND \d+`, want: `"AND" AND \d+`},
+		{input: `a(b|c)d AND (x(y) OR z)`, want: `"a(b|c)d" AND ("x(y)" OR z)`},
+		{input: `[)(]\(x AND y`, want: `"[)(]\\(x" AND y`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := ParseExpr(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+			if got := e.String(); got != tt.want {
+				t.Errorf("ParseExpr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpr_error(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: ``, want: "expected a pattern at 0, got end of expression"},
+		{input: `a AND`, want: "expected a pattern at 5, got end of expression"},
+		{input: `(a OR b`, want: "expected ) at 7, got end of expression"},
+		{input: `a b`, want: `unexpected "b" at 2`},
+		{input: `a)`, want: "unexpected ) at 1"},
+		{input: `"abc`, want: "unterminated quoted pattern at 0"},
+		{input: `a AND a**`, want: "invalid pattern at 6: error parsing regexp: invalid nested repetition operator: `**`"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParseExpr(tt.input)
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			if got := err.Error(); got != tt.want {
+				t.Errorf("ParseExpr() error = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_Plan(t *testing.T) {
+	tests := []struct {
+		input       string
+		wantQuery   string
+		wantVerify  []string
+		wantDecided []string
+	}{
+		{input: `abc`, wantQuery: `"abc"`, wantDecided: []string{`abc`}},
+		{input: `abc AND (def OR ghi) AND NOT jkl`, wantQuery: `"abc" ("def"|"ghi")`, wantDecided: []string{`abc`, `def`, `ghi`, `jkl`}},
+		{input: `abc OR NOT def`, wantQuery: `+`, wantDecided: []string{`abc`, `def`}},
+		{input: `^foo\d+ AND bar`, wantQuery: `("foo0"|"foo1"|"foo2"|"foo3"|"foo4"|"foo5"|"foo6"|"foo7"|"foo8"|"foo9") "bar"`, wantVerify: []string{`^foo\d+`}, wantDecided: []string{`bar`}},
+		{input: `a.*b AND a.*b`, wantQuery: `"a" "b"`, wantVerify: []string{`a.*b`}},
+		{input: `a\x{FFFD}b`, wantQuery: "\"a\ufffdb\"", wantVerify: []string{`a\x{FFFD}b`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := ParseExpr(tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+			p := NewAnalyzer().Plan(e)
+			if got := p.Query.String(); got != tt.wantQuery {
+				t.Errorf("Query = %v, want %v", got, tt.wantQuery)
+			}
+			if got := exprPatterns(p.Verify); strings.Join(got, ",") != strings.Join(tt.wantVerify, ",") {
+				t.Errorf("Verify = %v, want %v", got, tt.wantVerify)
+			}
+			if got := exprPatterns(p.Decided); strings.Join(got, ",") != strings.Join(tt.wantDecided, ",") {
+				t.Errorf("Decided = %v, want %v", got, tt.wantDecided)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_Plan_sound(t *testing.T) {
+	exprs := []string{
+		`abc AND (def OR ghi) AND NOT jkl`,
+		"`(a|b)c` AND NOT c",
+		`^foo\d+ AND bar OR baz$`,
+		`x.*y OR (NOT z AND w)`,
+		"`(?i)ab` AND c+",
+	}
+	texts := []string{"", "abc def", "abcghi jkl", "ac", "bc", "foo1 bar", "xbaz", "x y", "w", "wz", "AbC", "aB ccc"}
+	for _, input := range exprs {
+		e, err := ParseExpr(input)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		p := NewAnalyzer().Plan(e)
+		for _, text := range texts {
+			match := e.Eval(func(re *Expr) bool {
+				return regexp.MustCompile(re.Pattern).MatchString(text)
+			})
+			if match && !queryMatches(p.Query, text) {
+				t.Errorf("%s: query %v rejects %q", input, p.Query, text)
+			}
+			decided := e.Eval(func(re *Expr) bool {
+				for _, v := range p.Decided {
+					if v.Pattern == re.Pattern {
+						return NewAnalyzer().Factor(re.Regexp).Exact.MatchesText([]byte(text))
+					}
+				}
+				return regexp.MustCompile(re.Pattern).MatchString(text)
+			})
+			if match != decided {
+				t.Errorf("%s: decided by literals = %v, want %v for %q", input, decided, match, text)
+			}
+		}
+	}
+}
+
+func exprPatterns(es []*Expr) []string {
+	var ret []string
+	for _, v := range es {
+		ret = append(ret, v.Pattern)
+	}
+	return ret
+}
+
+// queryMatches returns true if the text satisfies the query.
+func queryMatches(q *Query, text string) bool {
+	switch q.Op {
+	case QAll:
+		return true
+	case QNone:
+		return false
+	case QAnd:
+		for _, v := range q.Literal {
+			if !strings.Contains(text, v) {
+				return false
+			}
+		}
+		for _, v := range q.Sub {
+			if !queryMatches(v, text) {
+				return false
+			}
+		}
+		return true
+	case QOr:
+		for _, v := range q.Literal {
+			if strings.Contains(text, v) {
+				return true
+			}
+		}
+		for _, v := range q.Sub {
+			if queryMatches(v, text) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
@@ -2,8 +2,12 @@ package factors
 
 import (
 	"fmt"
+
+	"github.com/ikawaha/factors/factors/ahocorasick"
 )
 
+const repeatExactLimit = 10
+
 // Factor represents a tuple of necessary factors for a regexp.
 type Factor struct {
 	Exact    Set
@@ -79,19 +83,156 @@ func Alternate(a, b Factor) Factor {
 
 // Concatenate represents `a・b`
 func Concatenate(a, b Factor) Factor {
+	return concatenate(a, b, nil)
+}
+
+// concatenate represents `a・b`, and appends the choices of the sets to the trace if it is not nil.
+func concatenate(a, b Factor, trace *[]Choice) Factor {
 	var ret Factor
 	ret.Exact = CrossSet(a.Exact, b.Exact)
 
 	ep := CrossSet(a.Exact, b.Prefix)
 	ep.DropRedundantPrefix()
-	ret.Prefix = BestSet(a.Prefix, ep)
+	ret.Prefix = choose(trace, "Prefix",
+		Candidate{Label: "a.Prefix", Set: a.Prefix},
+		Candidate{Label: "a.Exact・b.Prefix", Set: ep})
 
 	se := CrossSet(a.Suffix, b.Exact)
 	se.DropRedundantSuffix()
-	ret.Suffix = BestSet(b.Suffix, se)
+	ret.Suffix = choose(trace, "Suffix",
+		Candidate{Label: "b.Suffix", Set: b.Suffix},
+		Candidate{Label: "a.Suffix・b.Exact", Set: se})
 
 	sp := CrossSet(a.Suffix, b.Prefix)
 	sp.DropRedundantFragment()
-	ret.Fragment = BestSet(a.Fragment, b.Fragment, sp)
+	ret.Fragment = choose(trace, "Fragment",
+		Candidate{Label: "a.Fragment", Set: a.Fragment},
+		Candidate{Label: "b.Fragment", Set: b.Fragment},
+		Candidate{Label: "a.Suffix・b.Prefix", Set: sp})
+	return ret
+}
+
+// IntersectFactor represents that both a and b match the same text, e.g. a document, at any positions.
+// The matches do not have to cover the text nor share their boundaries, so the text has no exact, prefix
+// and suffix factors, and it contains an item of each fragment set. A set cannot represent both of them,
+// so the fragment set is the better one. Use a.Query().And(b.Query()) to require both.
+func IntersectFactor(a, b Factor) Factor {
+	ret := NewFactorInfinite()
+	ret.Fragment = BestSet(a.Fragment, b.Fragment)
+	return ret
+}
+
+// Implies returns true if every text which satisfies the necessary factors of a also satisfies the ones of b.
+// It is conservative, i.e. it may return false even if it holds.
+func Implies(a, b Factor) bool {
+	if !a.Exact.IsSubsetOf(b.Exact) {
+		return false
+	}
+	if !b.Prefix.infinite && !coveredBy(everyHasPrefix, b.Prefix, a.Prefix, a.Exact) {
+		return false
+	}
+	if !b.Suffix.infinite && !coveredBy(everyHasSuffix, b.Suffix, a.Suffix, a.Exact) {
+		return false
+	}
+	if !b.Fragment.infinite && !coveredBy(everyHasFragment, b.Fragment, a.Fragment, a.Prefix, a.Suffix, a.Exact) {
+		return false
+	}
+	return true
+}
+
+// coveredBy returns true if every item of one of the finite sets xs satisfies the condition of y.
+func coveredBy(every func(x, y Set) bool, y Set, xs ...Set) bool {
+	for _, x := range xs {
+		if !x.infinite && every(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+// everyHasPrefix returns true if every item of x has a prefix in y.
+func everyHasPrefix(x, y Set) bool {
+loop:
+	for _, v := range x.list() {
+		for i := 0; i <= len(v); i++ {
+			if y.Contains(v[:i]) {
+				continue loop
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// everyHasSuffix returns true if every item of x has a suffix in y.
+func everyHasSuffix(x, y Set) bool {
+loop:
+	for _, v := range x.list() {
+		for i := 0; i <= len(v); i++ {
+			if y.Contains(v[i:]) {
+				continue loop
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// everyHasFragment returns true if every item of x contains an item of y.
+func everyHasFragment(x, y Set) bool {
+	if y.Contains("") {
+		return true
+	}
+	ac := ahocorasick.New(y.list())
+	for _, v := range x.list() {
+		found := false
+		ac.OverlappingString(v, func(ahocorasick.Match) bool {
+			found = true
+			return false
+		})
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Repeat represents `a{min,max}` (1 <= min <= max).
+func Repeat(a Factor, min, max int) Factor {
+	return repeat(a, min, max, nil)
+}
+
+// repeat represents `a{min,max}` (1 <= min <= max), and appends the choices of the sets to the trace if it is not nil.
+func repeat(a Factor, min, max int, trace *[]Choice) Factor {
+	ret := a
+	for i := 1; i < min; i++ {
+		ret = concatenate(ret, a, trace)
+		setStep(trace, i)
+	}
+	if ret.Exact.infinite {
+		return ret
+	}
+	// a{min,max} matches one of a^min, ..., a^max.
+	power := ret.Exact
+	for i := min; i < max; i++ {
+		power = CrossSet(power, a.Exact)
+		ret.Exact = UnionSet(ret.Exact, power)
+	}
+	return ret
+}
+
+// repeatSize returns the number of items of the set repeated n times, or -1 if the set is infinite.
+// It saturates at repeatExactLimit+1.
+func repeatSize(s Set, n int) int {
+	if s.infinite {
+		return -1
+	}
+	ret := 1
+	for i := 0; i < n; i++ {
+		ret *= s.size()
+		if ret > repeatExactLimit {
+			return repeatExactLimit + 1
+		}
+	}
 	return ret
 }
@@ -2,6 +2,7 @@ package factors
 
 import (
 	"reflect"
+	"regexp/syntax"
 	"testing"
 )
 
@@ -97,6 +98,113 @@ func TestConcatenate(t *testing.T) {
 	}
 }
 
+func TestIntersectFactor(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Factor
+		want Factor
+	}{
+		{
+			name: "θ∧<{a}, {a}, {a}, {a}>",
+			a:    NewFactorInfinite(),
+			b:    NewFactorLiteral("a"),
+			want: Factor{
+				Exact:    Set{infinite: true},
+				Prefix:   Set{infinite: true},
+				Suffix:   Set{infinite: true},
+				Fragment: NewSet("a"),
+			},
+		},
+		{
+			// A document "ab y" contains the matches of both, so it has no exact factors.
+			name: "<{ab, x}, {ab, x}, {ab, x}, {ab, x}>∧<{abc, y}, {abc, y}, {b, y}, {abc}>",
+			a: Factor{
+				Exact:    NewSet("ab", "x"),
+				Prefix:   NewSet("ab", "x"),
+				Suffix:   NewSet("ab", "x"),
+				Fragment: NewSet("ab", "x"),
+			},
+			b: Factor{
+				Exact:    NewSet("abc", "y"),
+				Prefix:   NewSet("abc", "y"),
+				Suffix:   NewSet("b", "y"),
+				Fragment: NewSet("abc"),
+			},
+			want: Factor{
+				Exact:    Set{infinite: true},
+				Prefix:   Set{infinite: true},
+				Suffix:   Set{infinite: true},
+				Fragment: NewSet("abc"),
+			},
+		},
+		{
+			name: "<{}, {a}, {z}, {a, z}>∧<θ, {ab}, θ, {b}>",
+			a: Factor{
+				Exact:    NewSet(),
+				Prefix:   NewSet("a"),
+				Suffix:   NewSet("z"),
+				Fragment: NewSet("a", "z"),
+			},
+			b: Factor{
+				Exact:    Set{infinite: true},
+				Prefix:   NewSet("ab"),
+				Suffix:   Set{infinite: true},
+				Fragment: NewSet("b"),
+			},
+			want: Factor{
+				Exact:    Set{infinite: true},
+				Prefix:   Set{infinite: true},
+				Suffix:   Set{infinite: true},
+				Fragment: NewSet("b"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IntersectFactor(tt.a, tt.b)
+			if !got.Exact.Equal(tt.want.Exact) || !got.Prefix.Equal(tt.want.Prefix) ||
+				!got.Suffix.Equal(tt.want.Suffix) || !got.Fragment.Equal(tt.want.Fragment) {
+				t.Errorf("IntersectFactor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImplies(t *testing.T) {
+	factor := func(s string) Factor {
+		re, err := syntax.Parse(s, syntax.Perl)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		return NewAnalyzer().Factor(re)
+	}
+	tests := []struct {
+		name string
+		a, b Factor
+		want bool
+	}{
+		{name: "θ implies θ", a: NewFactorInfinite(), b: NewFactorInfinite(), want: true},
+		{name: "literal implies θ", a: NewFactorLiteral("a"), b: NewFactorInfinite(), want: true},
+		{name: "θ does not imply literal", a: NewFactorInfinite(), b: NewFactorLiteral("a"), want: false},
+		{name: "same", a: factor(`abc|def`), b: factor(`abc|def`), want: true},
+		{name: "alternative", a: factor(`abc`), b: factor(`abc|def`), want: true},
+		{name: "wider alternative", a: factor(`abc|def`), b: factor(`abc`), want: false},
+		{name: "prefix", a: factor(`abc.*xyz`), b: factor(`ab.*`), want: true},
+		{name: "suffix", a: factor(`abc.*xyz`), b: factor(`.*yz`), want: true},
+		{name: "fragment", a: factor(`.*abc.*`), b: factor(`.*b.*`), want: true},
+		{name: "fragment of exact", a: factor(`(?:xaz|ybz)`), b: factor(`.*(?:a|b).*`), want: true},
+		{name: "missing fragment", a: factor(`.*abc.*`), b: factor(`.*d.*`), want: false},
+		{name: "exact is narrower", a: factor(`abc`), b: factor(`b`), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Implies(tt.a, tt.b); got != tt.want {
+				t.Errorf("Implies(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestFactor_String(t *testing.T) {
 	type fields struct {
 		Exact    Set
@@ -0,0 +1,245 @@
+package factors
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+const defaultFastPatternIterations = 100
+
+// FastPatternSelector chooses a set of literals, i.e. the fast pattern, for each rule of a rule set,
+// which a multi-pattern engine looks for before running the rule.
+// Choosing the best set of each rule by BestSet may key many rules on the same common literal, e.g. "http",
+// then an occurrence of it runs all of them. The selector chooses the sets which minimize the total cost of the rules,
+// where the cost of a rule is the sum of the weights of its literals multiplied by the numbers of the rules keyed on them.
+// The zero value is ready to use.
+type FastPatternSelector struct {
+	// Weight is the expected frequency of a literal, 1/len(literal) if nil, i.e. a longer literal is rarer.
+	Weight func(literal string) float64
+	// MaxIterations is the maximum number of the rounds over the rules, 100 if it is not positive.
+	MaxIterations int
+}
+
+// FastPattern represents the choice of the fast pattern of a rule.
+type FastPattern struct {
+	// Candidates are the finite sets of the factor, i.e. Exact, Prefix, Suffix, Fragment,
+	// and the sets of the conjunction of its query, which every match requires.
+	Candidates []Candidate
+	// Local is the index of the candidate which BestSet chooses, and Winner is the index of the chosen one.
+	// They are -1 if the rule has no candidates, then it runs for every text.
+	Local, Winner int
+	// Cost is the cost of the chosen set.
+	Cost float64
+}
+
+// SharedKey represents a literal which keys two or more rules.
+type SharedKey struct {
+	Literal string
+	// Rules are the indexes of the rules keyed on the literal.
+	Rules []int
+}
+
+// FastPatternReport represents the fast patterns of a rule set.
+type FastPatternReport struct {
+	Rules []FastPattern
+	// Shared is the shared keys in the descending order of the numbers of the rules.
+	Shared []SharedKey
+	// Cost is the total cost of the chosen sets, and LocalCost is the one of the sets which BestSet chooses.
+	Cost, LocalCost float64
+	// Iterations is the number of the rounds over the rules until no rule changes its set.
+	Iterations int
+}
+
+// SelectFastPatterns chooses the fast patterns of the rules by the zero value of FastPatternSelector.
+func SelectFastPatterns(rules []Factor) *FastPatternReport {
+	return FastPatternSelector{}.Select(rules)
+}
+
+// Select chooses the fast patterns of the rules.
+// It starts from the sets which BestSet chooses, and lets each rule change its set to the cheapest one
+// for the current choices of the other rules until no rule changes it. Every change decreases
+// the sum of weight(l)·n(l)·(n(l)+1)/2 over the literals l, where n(l) is the number of the rules keyed on l,
+// so it always ends.
+//
+//nolint:gocyclo
+func (s FastPatternSelector) Select(rules []Factor) *FastPatternReport {
+	weight := s.Weight
+	if weight == nil {
+		weight = func(literal string) float64 {
+			return 1 / float64(len(literal))
+		}
+	}
+	limit := s.MaxIterations
+	if limit <= 0 {
+		limit = defaultFastPatternIterations
+	}
+	ret := &FastPatternReport{Rules: make([]FastPattern, len(rules))}
+	items := make([][][]string, len(rules))
+	counts := map[string]int{}
+	for i, f := range rules {
+		r := &ret.Rules[i]
+		r.Candidates = fastPatternCandidates(f)
+		r.Local, r.Winner = -1, -1
+		for j, c := range r.Candidates {
+			if r.Local < 0 || better(c.Set, r.Candidates[r.Local].Set) {
+				r.Local = j
+			}
+			items[i] = append(items[i], c.Set.Items())
+		}
+		if r.Winner = r.Local; r.Winner >= 0 {
+			for _, v := range items[i][r.Winner] {
+				counts[v]++
+			}
+		}
+	}
+	cost := func(literals []string, delta int) float64 {
+		var ret float64
+		for _, v := range literals {
+			ret += weight(v) * float64(counts[v]+delta)
+		}
+		return ret
+	}
+	ret.LocalCost = fastPatternCost(ret.Rules, items, cost)
+	for changed := true; changed && ret.Iterations < limit; {
+		changed = false
+		ret.Iterations++
+		for i := range ret.Rules {
+			r := &ret.Rules[i]
+			if r.Winner < 0 {
+				continue
+			}
+			for _, v := range items[i][r.Winner] {
+				counts[v]--
+			}
+			// The rule keeps its set unless another one is cheaper.
+			best, lowest := r.Winner, cost(items[i][r.Winner], 1)
+			for j := range r.Candidates {
+				if c := cost(items[i][j], 1); c < lowest {
+					best, lowest = j, c
+				}
+			}
+			if best != r.Winner {
+				r.Winner, changed = best, true
+			}
+			for _, v := range items[i][r.Winner] {
+				counts[v]++
+			}
+		}
+	}
+	ret.Cost = fastPatternCost(ret.Rules, items, cost)
+	for i, r := range ret.Rules {
+		ret.Rules[i].Cost = 0
+		if r.Winner >= 0 {
+			ret.Rules[i].Cost = cost(items[i][r.Winner], 0)
+		}
+	}
+	ret.Shared = sharedKeys(ret.Rules, items)
+	return ret
+}
+
+// fastPatternCandidates returns the finite sets of the factor which require non-empty literals without duplicates.
+func fastPatternCandidates(f Factor) []Candidate {
+	var ret []Candidate
+	add := func(label string, s Set) {
+		if s.infinite || s.size() == 0 || s.Contains("") {
+			return
+		}
+		for _, v := range ret {
+			if v.Set.Equal(s) {
+				return
+			}
+		}
+		ret = append(ret, Candidate{Label: label, Set: s, MinimumLen: s.minimumLen, Size: s.Len()})
+	}
+	add("Exact", f.Exact)
+	add("Prefix", f.Prefix)
+	add("Suffix", f.Suffix)
+	add("Fragment", f.Fragment)
+	for i, v := range conjunctiveSets(f.Query().Simplify()) {
+		add("Conjunct["+strconv.Itoa(i)+"]", v)
+	}
+	return ret
+}
+
+// conjunctiveSets returns the sets of literals which the query requires one of each,
+// i.e. the literals and the disjunctions of literals of the conjunction.
+func conjunctiveSets(q *Query) []Set {
+	var ret []Set
+	switch q.Op {
+	case QAnd:
+		for _, v := range q.Literal {
+			ret = append(ret, NewSet(v))
+		}
+		for _, v := range q.Sub {
+			if v.Op == QOr && len(v.Sub) == 0 {
+				ret = append(ret, NewSet(v.Literal...))
+			}
+		}
+	case QOr:
+		if len(q.Sub) == 0 {
+			ret = append(ret, NewSet(q.Literal...))
+		}
+	}
+	return ret
+}
+
+// fastPatternCost returns the total cost of the chosen sets of the rules.
+func fastPatternCost(rules []FastPattern, items [][][]string, cost func(literals []string, delta int) float64) float64 {
+	var ret float64
+	for i, r := range rules {
+		if r.Winner >= 0 {
+			ret += cost(items[i][r.Winner], 0)
+		}
+	}
+	return ret
+}
+
+// sharedKeys returns the literals of the chosen sets which key two or more rules.
+func sharedKeys(rules []FastPattern, items [][][]string) []SharedKey {
+	m := map[string][]int{}
+	for i, r := range rules {
+		if r.Winner >= 0 {
+			for _, v := range items[i][r.Winner] {
+				m[v] = append(m[v], i)
+			}
+		}
+	}
+	var ret []SharedKey
+	for k, v := range m {
+		if len(v) > 1 {
+			ret = append(ret, SharedKey{Literal: k, Rules: v})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if len(ret[i].Rules) != len(ret[j].Rules) {
+			return len(ret[i].Rules) > len(ret[j].Rules)
+		}
+		return ret[i].Literal < ret[j].Literal
+	})
+	return ret
+}
+
+// Print writes the chosen sets of the rules, the shared keys and the costs.
+func (r *FastPatternReport) Print(w io.Writer) {
+	for i, v := range r.Rules {
+		if v.Winner < 0 {
+			fmt.Fprintf(w, "rule %d: θ (always)\n", i)
+			continue
+		}
+		c := v.Candidates[v.Winner]
+		fmt.Fprintf(w, "rule %d: %s = %s (cost: %.3f", i, c.Label, abbr(c.Set.String()), v.Cost)
+		if v.Local != v.Winner {
+			fmt.Fprintf(w, ", local: %s = %s", v.Candidates[v.Local].Label, abbr(v.Candidates[v.Local].Set.String()))
+		}
+		fmt.Fprintln(w, ")")
+	}
+	if len(r.Shared) > 0 {
+		fmt.Fprintln(w, "shared keys:")
+		for _, v := range r.Shared {
+			fmt.Fprintf(w, "  %q: %d rules %v\n", v.Literal, len(v.Rules), v.Rules)
+		}
+	}
+	fmt.Fprintf(w, "total cost: %.3f (local: %.3f, iterations: %d)\n", r.Cost, r.LocalCost, r.Iterations)
+}
@@ -0,0 +1,99 @@
+package factors
+
+import (
+	"bytes"
+	"reflect"
+	"regexp/syntax"
+	"testing"
+)
+
+func TestSelectFastPatterns(t *testing.T) {
+	patterns := []string{
+		`http.*abc`,
+		`http.*cde`,
+		`http.*efg`,
+		`.*`,
+	}
+	var rules []Factor
+	for _, v := range patterns {
+		re, err := syntax.Parse(v, syntax.Perl)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		rules = append(rules, NewAnalyzer().Factor(re))
+	}
+	r := SelectFastPatterns(rules)
+	// The last rule keyed on "http" keeps it.
+	want := []string{"{abc}", "{cde}", "{http}", ""}
+	for i, v := range r.Rules {
+		var got string
+		if v.Winner >= 0 {
+			got = v.Candidates[v.Winner].Set.String()
+		}
+		if got != want[i] {
+			t.Errorf("rule %d (%s): got %s, want %s", i, patterns[i], got, want[i])
+		}
+	}
+	if got, want := r.Shared, []SharedKey(nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("Shared = %v, want %v", got, want)
+	}
+	if r.Cost > r.LocalCost {
+		t.Errorf("Cost = %v, want not greater than LocalCost %v", r.Cost, r.LocalCost)
+	}
+	var b bytes.Buffer
+	r.Print(&b)
+	wantPrint := `rule 0: Suffix = {abc} (cost: 0.333, local: Prefix = {http})
+rule 1: Suffix = {cde} (cost: 0.333, local: Prefix = {http})
+rule 2: Prefix = {http} (cost: 0.250)
+rule 3: θ (always)
+total cost: 0.917 (local: 2.250, iterations: 2)
+`
+	if got := b.String(); got != wantPrint {
+		t.Errorf("Print() = %s, want %s", got, wantPrint)
+	}
+}
+
+func TestFastPatternSelector_Select(t *testing.T) {
+	rules := []Factor{
+		{Exact: Set{infinite: true}, Prefix: NewSet("http"), Suffix: Set{infinite: true}, Fragment: NewSet("abc")},
+		{Exact: Set{infinite: true}, Prefix: NewSet("http"), Suffix: Set{infinite: true}, Fragment: NewSet("xyz")},
+		{Exact: Set{infinite: true}, Prefix: NewSet("http"), Suffix: Set{infinite: true}, Fragment: Set{infinite: true}},
+	}
+	tests := []struct {
+		name   string
+		weight func(string) float64
+		want   []int
+		shared []SharedKey
+	}{
+		{
+			name: "the zero value",
+			// "http" is preferred locally, but it is shared by the three rules.
+			want:   []int{1, 1, 0},
+			shared: nil,
+		},
+		{
+			name: "the literal is cheap",
+			weight: func(literal string) float64 {
+				if literal == "http" {
+					return 0.1
+				}
+				return 1
+			},
+			want:   []int{0, 0, 0},
+			shared: []SharedKey{{Literal: "http", Rules: []int{0, 1, 2}}},
+		},
+	}
+	for _, tt := range tests {
+		r := FastPatternSelector{Weight: tt.weight}.Select(rules)
+		var got []int
+		for _, v := range r.Rules {
+			got = append(got, v.Winner)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: winners = %v, want %v", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(r.Shared, tt.shared) {
+			t.Errorf("%s: Shared = %v, want %v", tt.name, r.Shared, tt.shared)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package factors
+
+import (
+	"regexp/syntax"
+)
+
+// Group represents necessary factors of a capture group.
+type Group struct {
+	Index  int
+	Name   string // empty if the group is unnamed.
+	Factor Factor
+	// MinLen is the minimum length in bytes of the strings the group matches.
+	MinLen int
+	// MaxLen is the maximum length in bytes of the strings the group matches, -1 if unbounded.
+	MaxLen int
+}
+
+// Groups represents capture groups by their indexes.
+type Groups map[int]Group
+
+// Name returns the group of a given name.
+func (g Groups) Name(name string) (Group, bool) {
+	for _, v := range g {
+		if name != "" && v.Name == name {
+			return v, true
+		}
+	}
+	return Group{}, false
+}
+
+// Groups analyzes a given regexp and returns the necessary factors of each capture group.
+// The group 0 is the whole regexp as the regexp package numbers the groups.
+// The factors of a group are the ones of its sub expression, i.e. they are required
+// only if the group participates in a match.
+func (a Analyzer) Groups(re *syntax.Regexp) Groups {
+	// The groups are analyzed again after the whole, so reuse the factors of the sub expressions.
+	if a.cache == nil {
+		a.cache = NewCache()
+	}
+	m := a.newMemo()
+	lo, hi := lengthRange(re)
+	ret := Groups{
+		0: {Factor: m.analyze(re, false).Factor, MinLen: lo, MaxLen: hi},
+	}
+	// The groups are analyzed in the analysis of the whole, so they are not observed again.
+	m.observer = nil
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		if re.Op == syntax.OpCapture {
+			lo, hi := lengthRange(re.Sub[0])
+			ret[re.Cap] = Group{
+				Index:  re.Cap,
+				Name:   re.Name,
+				Factor: m.analyze(re.Sub[0], false).Factor,
+				MinLen: lo,
+				MaxLen: hi,
+			}
+		}
+		for _, sub := range re.Sub {
+			walk(sub)
+		}
+	}
+	walk(re)
+	return ret
+}
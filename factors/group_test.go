@@ -0,0 +1,82 @@
+package factors
+
+import (
+	"regexp/syntax"
+	"testing"
+)
+
+func TestAnalyzer_Groups(t *testing.T) {
+	re, err := syntax.Parse(`^(?P<user>[a-z]+)@(\d{1,3})\.(?P<host>example\.(?:com|org))( .*)?$`, syntax.Perl)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	got := NewAnalyzer().Groups(re)
+	tests := []struct {
+		index    int
+		name     string
+		fragment string
+		minLen   int
+		maxLen   int
+	}{
+		{index: 0, fragment: "{0.example.com, 0.example.org, 1.example.com, 1.example.org, 2.example.com, 2.example.org, 3.example.com, 3.example.org, 4.example.com, 4.example.org, 5.example.com, 5.example.org, 6.example.com, 6.example.org, 7.example.com, 7.example.org, 8.example.com, 8.example.org, 9.example.com, 9.example.org}", minLen: 15, maxLen: -1},
+		{index: 1, name: "user", fragment: "{a, b, c, d, e, f, g, h, i, j, k, l, m, n, o, p, q, r, s, t, u, v, w, x, y, z}", minLen: 1, maxLen: -1},
+		{index: 2, fragment: "{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}", minLen: 1, maxLen: 3},
+		{index: 3, name: "host", fragment: "{example.com, example.org}", minLen: 11, maxLen: 11},
+		{index: 4, fragment: "{ }", minLen: 1, maxLen: -1},
+	}
+	if len(got) != len(tests) {
+		t.Fatalf("len(Groups()) = %d, want %d", len(got), len(tests))
+	}
+	for _, tt := range tests {
+		g, ok := got[tt.index]
+		if !ok {
+			t.Fatalf("group %d not found", tt.index)
+		}
+		if g.Index != tt.index || g.Name != tt.name || g.Factor.Fragment.String() != tt.fragment || g.MinLen != tt.minLen || g.MaxLen != tt.maxLen {
+			t.Errorf("group %d = %+v, want %+v", tt.index, g, tt)
+		}
+		if tt.name == "" {
+			continue
+		}
+		if g, ok := got.Name(tt.name); !ok || g.Index != tt.index {
+			t.Errorf("Name(%q) = %+v, %v, want index %d", tt.name, g, ok, tt.index)
+		}
+	}
+	if _, ok := got.Name("unknown"); ok {
+		t.Errorf("Name(unknown) is found")
+	}
+}
+
+func Test_lengthRange(t *testing.T) {
+	tests := []struct {
+		pattern string
+		lo, hi  int
+	}{
+		{pattern: `abc`, lo: 3, hi: 3},
+		{pattern: `寿司`, lo: 6, hi: 6},
+		{pattern: `(?i)k`, lo: 1, hi: 3},
+		{pattern: `[a-zあ]`, lo: 1, hi: 3},
+		{pattern: `.`, lo: 1, hi: 4},
+		{pattern: `a|bcd`, lo: 1, hi: 3},
+		{pattern: `a|b*`, lo: 0, hi: -1},
+		{pattern: `ab+`, lo: 2, hi: -1},
+		{pattern: `ab?`, lo: 1, hi: 2},
+		{pattern: `(?:ab){2,3}`, lo: 4, hi: 6},
+		{pattern: `(?:ab){2,}`, lo: 4, hi: -1},
+		{pattern: `^\b$`, lo: 0, hi: 0},
+		{pattern: `a\x{FFFD}b`, lo: 3, hi: 5},
+		{pattern: `[\x{FFF0}-\x{FFFF}]`, lo: 1, hi: 3},
+		{pattern: `(?i)\x{FFFD}`, lo: 1, hi: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			re, err := syntax.Parse(tt.pattern, syntax.Perl)
+			if err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+			if lo, hi := lengthRange(re); lo != tt.lo || hi != tt.hi {
+				t.Errorf("lengthRange() = %d, %d, want %d, %d", lo, hi, tt.lo, tt.hi)
+			}
+		})
+	}
+}
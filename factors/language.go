@@ -0,0 +1,229 @@
+package factors
+
+import (
+	"math/big"
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+const (
+	surrogateMin = 0xD800
+	surrogateMax = 0xDFFF
+)
+
+// Language represents the set of strings which a regexp matches.
+// Empty-width assertions are treated as always satisfied.
+type Language struct {
+	d    *dfa
+	live []bool
+}
+
+// Language returns the language of a given regexp.
+// It returns ErrTooLarge if the pattern exceeds the size limit of the DFA construction.
+func (a Analyzer) Language(re *syntax.Regexp) (*Language, error) {
+	d, err := newDFA(re)
+	if err != nil {
+		return nil, err
+	}
+	return &Language{
+		d:    d,
+		live: d.live(),
+	}, nil
+}
+
+// Finite returns true if the language is finite.
+func (l *Language) Finite() bool {
+	const (
+		white = iota
+		gray
+		black
+	)
+	color := make([]int, len(l.d.next))
+	var cyclic func(s int) bool
+	cyclic = func(s int) bool {
+		color[s] = gray
+		for c, t := range l.d.next[s] {
+			if !l.edge(c, t) {
+				continue
+			}
+			if color[t] == gray || (color[t] == white && cyclic(t)) {
+				return true
+			}
+		}
+		color[s] = black
+		return false
+	}
+	return !l.live[l.d.start] || !cyclic(l.d.start)
+}
+
+// Count returns the number of strings in the language, or nil if the language is infinite.
+func (l *Language) Count() *big.Int {
+	if !l.Finite() {
+		return nil
+	}
+	memo := make([]*big.Int, len(l.d.next))
+	var count func(s int) *big.Int
+	count = func(s int) *big.Int {
+		if memo[s] != nil {
+			return memo[s]
+		}
+		ret := new(big.Int)
+		if l.d.accept[s] {
+			ret.SetInt64(1)
+		}
+		for c, t := range l.d.next[s] {
+			if !l.edge(c, t) {
+				continue
+			}
+			n := new(big.Int).SetInt64(classSize(l.d.classes[c]))
+			ret.Add(ret, n.Mul(n, count(t)))
+		}
+		memo[s] = ret
+		return ret
+	}
+	if !l.live[l.d.start] {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(count(l.d.start))
+}
+
+// Enumerate calls fn for each string of the language in order of length, then of runes.
+// It stops when limit strings are enumerated or fn returns false. A negative limit means no limit,
+// then enumerating an infinite language does not stop until fn returns false.
+func (l *Language) Enumerate(limit int, fn func(s string) bool) {
+	if limit == 0 || !l.live[l.d.start] {
+		return
+	}
+	maxLen := -1 // unbounded
+	if l.Finite() {
+		maxLen = l.longest()
+	}
+	e := enumerator{
+		l:     l,
+		limit: limit,
+		fn:    fn,
+		buf:   make([]byte, 0, utf8.UTFMax*8),
+		// A string of 0 runes from a state is accepted if the state accepts.
+		accepts: [][]bool{l.d.accept},
+	}
+	for length := 0; maxLen < 0 || length <= maxLen; length++ {
+		if length > 0 {
+			e.accepts = append(e.accepts, l.acceptsIn(e.accepts[length-1]))
+		}
+		if e.accepts[length][l.d.start] && !e.walk(l.d.start, length) {
+			return
+		}
+	}
+}
+
+// enumerator enumerates the strings of a language of a length.
+type enumerator struct {
+	l     *Language
+	limit int
+	n     int
+	fn    func(s string) bool
+	buf   []byte
+	// accepts[k][s] reports whether a string of k runes from the state s is accepted,
+	// so that walk never goes into a branch which has no string of the length.
+	accepts [][]bool
+}
+
+// walk enumerates the strings of rest runes from the state s following the buffer,
+// and returns false if the enumeration stops.
+func (e *enumerator) walk(s, rest int) bool {
+	if rest == 0 {
+		e.n++
+		return e.fn(string(e.buf)) && e.n != e.limit
+	}
+	for c, t := range e.l.d.next[s] {
+		if !e.l.edge(c, t) || !e.accepts[rest-1][t] {
+			continue
+		}
+		rr := e.l.d.classes[c]
+		for r := rr.lo; r <= rr.hi; r++ {
+			if !utf8.ValidRune(r) {
+				r = surrogateMax
+				continue
+			}
+			w := len(e.buf)
+			e.buf = append(e.buf, string(r)...)
+			ok := e.walk(t, rest-1)
+			e.buf = e.buf[:w]
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// acceptsIn returns for each state whether a string of k+1 runes from the state is accepted,
+// given for each state whether a string of k runes from the state is accepted.
+func (l *Language) acceptsIn(accepts []bool) []bool {
+	ret := make([]bool, len(l.d.next))
+	for s := range l.d.next {
+		for c, t := range l.d.next[s] {
+			if l.edge(c, t) && accepts[t] {
+				ret[s] = true
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// Strings returns the strings of the language up to limit.
+func (l *Language) Strings(limit int) []string {
+	var ret []string
+	l.Enumerate(limit, func(s string) bool {
+		ret = append(ret, s)
+		return true
+	})
+	return ret
+}
+
+// edge returns true if the transition by the class c to the state t can reach an accepting state.
+func (l *Language) edge(c, t int) bool {
+	return t >= 0 && l.live[t] && classSize(l.d.classes[c]) > 0
+}
+
+// longest returns the number of runes of the longest string in the finite language.
+func (l *Language) longest() int {
+	memo := make([]int, len(l.d.next))
+	for i := range memo {
+		memo[i] = -1
+	}
+	var depth func(s int) int
+	depth = func(s int) int {
+		if memo[s] >= 0 {
+			return memo[s]
+		}
+		ret := 0
+		for c, t := range l.d.next[s] {
+			if l.edge(c, t) {
+				if d := depth(t) + 1; d > ret {
+					ret = d
+				}
+			}
+		}
+		memo[s] = ret
+		return ret
+	}
+	return depth(l.d.start)
+}
+
+// classSize returns the number of valid runes in the range.
+func classSize(rr runeRange) int64 {
+	n := int64(rr.hi) - int64(rr.lo) + 1
+	lo, hi := rr.lo, rr.hi
+	if lo < surrogateMin {
+		lo = surrogateMin
+	}
+	if hi > surrogateMax {
+		hi = surrogateMax
+	}
+	if lo <= hi {
+		n -= int64(hi) - int64(lo) + 1
+	}
+	return n
+}
@@ -0,0 +1,119 @@
+package factors
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		name    string
+		re      string
+		finite  bool
+		count   *big.Int
+		strings []string
+	}{
+		{
+			name:    "literal",
+			re:      `abc`,
+			finite:  true,
+			count:   big.NewInt(1),
+			strings: []string{"abc"},
+		},
+		{
+			name:    "ambiguous alternatives",
+			re:      `a|a|ab`,
+			finite:  true,
+			count:   big.NewInt(2),
+			strings: []string{"a", "ab"},
+		},
+		{
+			name:    "bounded repeat",
+			re:      `[ab]{1,2}`,
+			finite:  true,
+			count:   big.NewInt(6),
+			strings: []string{"a", "b", "aa", "ab", "ba", "bb"},
+		},
+		{
+			name:    "any char",
+			re:      `(?s).`,
+			finite:  true,
+			count:   big.NewInt(0x10FFFF + 1 - 0x800),
+			strings: []string{"\x00", "\x01", "\x02", "\x03", "\x04", "\x05"},
+		},
+		{
+			name:    "star",
+			re:      `ab*`,
+			finite:  false,
+			count:   nil,
+			strings: []string{"a", "ab", "abb", "abbb", "abbbb", "abbbbb"},
+		},
+		{
+			name:    "empty star",
+			re:      `(?:)*x`,
+			finite:  true,
+			count:   big.NewInt(1),
+			strings: []string{"x"},
+		},
+		{
+			name:    "no match",
+			re:      `[^\x00-\x{10FFFF}]`,
+			finite:  true,
+			count:   big.NewInt(0),
+			strings: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewAnalyzer().Language(syntaxRegexp(t, tt.re))
+			if err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+			if got := l.Finite(); got != tt.finite {
+				t.Errorf("Finite() = %v, want %v", got, tt.finite)
+			}
+			if got := l.Count(); (got == nil) != (tt.count == nil) || (got != nil && got.Cmp(tt.count) != 0) {
+				t.Errorf("Count() = %v, want %v", got, tt.count)
+			}
+			if got := l.Strings(6); !reflect.DeepEqual(got, tt.strings) {
+				t.Errorf("Strings() = %q, want %q", got, tt.strings)
+			}
+		})
+	}
+}
+
+func TestLanguage_Enumerate(t *testing.T) {
+	l, err := NewAnalyzer().Language(syntaxRegexp(t, `x[0-9]{2}`))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	var n int
+	l.Enumerate(-1, func(s string) bool {
+		n++
+		return s != "x42"
+	})
+	if n != 43 {
+		t.Errorf("Enumerate() stopped after %d strings, want 43", n)
+	}
+}
+
+func TestLanguage_Strings_DeadPrefix(t *testing.T) {
+	l, err := NewAnalyzer().Language(syntaxRegexp(t, `[^\n]{2}a`))
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	done := make(chan []string)
+	go func() {
+		done <- l.Strings(1)
+	}()
+	select {
+	case got := <-done:
+		if want := []string{"\x00\x00a"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Strings() = %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Strings() does not return")
+	}
+}
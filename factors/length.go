@@ -0,0 +1,112 @@
+package factors
+
+import (
+	"regexp/syntax"
+	"unicode"
+	"unicode/utf8"
+)
+
+// lengthRange returns the minimum and the maximum length in bytes of the strings the regexp matches.
+// The maximum is -1 if it is unbounded.
+// U+FFFD also matches an invalid UTF-8 byte, so it is at least 1 byte.
+//
+//nolint:gocyclo
+func lengthRange(re *syntax.Regexp) (lo, hi int) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			l, h := minRuneLen(r), utf8.RuneLen(r)
+			if re.Flags&syntax.FoldCase != 0 {
+				for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+					l, h = minInt(l, minRuneLen(f)), maxInt(h, utf8.RuneLen(f))
+				}
+			}
+			lo += l
+			hi += h
+		}
+		return lo, hi
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return 0, 0
+		}
+		lo, hi = utf8.UTFMax, 0
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			lo = minInt(lo, runeLen(re.Rune[i]))
+			hi = maxInt(hi, runeLen(re.Rune[i+1]))
+			if re.Rune[i] <= utf8.RuneError && utf8.RuneError <= re.Rune[i+1] {
+				lo = 1
+			}
+		}
+		return lo, hi
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return 1, utf8.UTFMax
+	case syntax.OpCapture:
+		return lengthRange(re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			l, h := lengthRange(sub)
+			lo += l
+			hi = addLen(hi, h)
+		}
+		return lo, hi
+	case syntax.OpAlternate:
+		for i, sub := range re.Sub {
+			l, h := lengthRange(sub)
+			if i == 0 || l < lo {
+				lo = l
+			}
+			if i == 0 || hi >= 0 && (h < 0 || h > hi) {
+				hi = h
+			}
+		}
+		return lo, hi
+	case syntax.OpStar:
+		return 0, -1
+	case syntax.OpPlus:
+		lo, _ = lengthRange(re.Sub[0])
+		return lo, -1
+	case syntax.OpQuest:
+		_, hi = lengthRange(re.Sub[0])
+		return 0, hi
+	case syntax.OpRepeat:
+		l, h := lengthRange(re.Sub[0])
+		lo = l * re.Min
+		if re.Max < 0 || h < 0 {
+			return lo, -1
+		}
+		return lo, h * re.Max
+	}
+	// empty width assertions, empty match and no match.
+	return 0, 0
+}
+
+// runeLen returns the length of the UTF-8 encoding of the rune, surrogates are encoded as the replacement character.
+func runeLen(r rune) int {
+	if n := utf8.RuneLen(r); n > 0 {
+		return n
+	}
+	return utf8.RuneLen(utf8.RuneError)
+}
+
+// minRuneLen returns the minimum length of the text which the rune matches.
+func minRuneLen(r rune) int {
+	if r == utf8.RuneError {
+		return 1
+	}
+	return utf8.RuneLen(r)
+}
+
+// addLen adds the lengths, -1 if either is unbounded.
+func addLen(x, y int) int {
+	if x < 0 || y < 0 {
+		return -1
+	}
+	return x + y
+}
+
+func maxInt(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
+}
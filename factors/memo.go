@@ -0,0 +1,166 @@
+package factors
+
+import (
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Cache memoizes factors of structurally identical sub expressions.
+// It is safe for concurrent use, so it can be shared among analyzers to reuse the factors across patterns.
+type Cache struct {
+	mu      sync.Mutex
+	ids     map[string]int
+	factors map[int]Factor
+}
+
+// NewCache creates a cache.
+func NewCache() *Cache {
+	return &Cache{
+		ids:     map[string]int{},
+		factors: map[int]Factor{},
+	}
+}
+
+// Len returns the number of structurally distinct sub expressions in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.ids)
+}
+
+// id returns the identifier of the structure of a sub expression.
+func (c *Cache) id(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id, ok := c.ids[key]; ok {
+		return id
+	}
+	id := len(c.ids)
+	c.ids[key] = id
+	return id
+}
+
+func (c *Cache) load(id int) (Factor, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.factors[id]
+	return f, ok
+}
+
+func (c *Cache) store(id int, f Factor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.factors[id] = f
+}
+
+// memo hash-conses the sub expressions of a regexp, i.e. structurally identical sub expressions share an identifier.
+// A nil memo or a memo without a cache analyzes without memoization.
+type memo struct {
+	cache    *Cache
+	ids      map[*syntax.Regexp]int
+	spans    map[*syntax.Regexp]Span // spans of the sub expressions to track the sources, if any.
+	observer Observer
+	// observed is the factors of the analysis with the observer, which may replace the factors,
+	// so they are not stored in the cache shared with the other analyzers.
+	observed map[int]Factor
+}
+
+func newMemo(c *Cache) *memo {
+	return &memo{
+		cache: c,
+		ids:   map[*syntax.Regexp]int{},
+	}
+}
+
+// analyze returns a node of a given regexp, it reuses the factor of a structurally identical sub expression.
+// The parse tree and the sources are never memoized.
+func (m *memo) analyze(re *syntax.Regexp, tree bool) *Node {
+	if re == nil {
+		return nil
+	}
+	if m == nil {
+		return m.analyzeOp(re, tree)
+	}
+	if m.observer != nil {
+		m.observer.Enter(re)
+	}
+	memoize := !tree && m.spans == nil && m.cache != nil
+	var (
+		n      *Node
+		id     int
+		cached bool
+	)
+	if memoize {
+		id = m.id(re)
+		if f, ok := m.load(id); ok {
+			n = &Node{
+				Factor: f,
+				Regexp: re,
+			}
+			cached = true
+		}
+	}
+	if n == nil {
+		n = m.analyzeOp(re, tree)
+		if m.spans != nil {
+			m.attachSource(re, &n.Factor)
+		}
+	}
+	if m.observer != nil {
+		n.Factor = m.observe(re, n.Factor, cached)
+	}
+	if memoize && !cached {
+		m.store(id, n.Factor)
+	}
+	return n
+}
+
+func (m *memo) load(id int) (Factor, bool) {
+	if m.observer != nil {
+		f, ok := m.observed[id]
+		return f, ok
+	}
+	return m.cache.load(id)
+}
+
+func (m *memo) store(id int, f Factor) {
+	if m.observer != nil {
+		if m.observed == nil {
+			m.observed = map[int]Factor{}
+		}
+		m.observed[id] = f
+		return
+	}
+	m.cache.store(id, f)
+}
+
+// id returns the identifier of the structure of a sub expression.
+// Capture indexes and names do not affect factors, so they are not a part of the structure.
+func (m *memo) id(re *syntax.Regexp) int {
+	if id, ok := m.ids[re]; ok {
+		return id
+	}
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(re.Op)))
+	b.WriteByte(':')
+	b.WriteString(strconv.Itoa(int(re.Flags & syntax.FoldCase)))
+	b.WriteByte(':')
+	b.WriteString(strconv.Itoa(re.Min))
+	b.WriteByte(':')
+	b.WriteString(strconv.Itoa(re.Max))
+	b.WriteByte(':')
+	for _, r := range re.Rune {
+		b.WriteString(strconv.Itoa(int(r)))
+		b.WriteByte(',')
+	}
+	b.WriteByte(':')
+	for _, sub := range re.Sub {
+		b.WriteString(strconv.Itoa(m.id(sub)))
+		b.WriteByte(',')
+	}
+	id := m.cache.id(b.String())
+	m.ids[re] = id
+	return id
+}
@@ -0,0 +1,46 @@
+package factors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzer_Factor_memo(t *testing.T) {
+	const ip = `(?:25[0-5]|2[0-4]\d|1?\d?\d)\.(?:25[0-5]|2[0-4]\d|1?\d?\d)`
+	patterns := []string{
+		`src=` + ip + ` dst=` + ip,
+		`(a+b|c)x(a+b|c)y(?P<name>a+b|c)`,
+		`(?i:abc)(?i:abc)`,
+		`(abc){2,3}(abc)+`,
+	}
+	for _, p := range patterns {
+		t.Run(p, func(t *testing.T) {
+			re := syntaxRegexp(t, p)
+			want := analyze(re, false).Factor
+			if got := NewAnalyzer(WithCache(NewCache())).Factor(re); !reflect.DeepEqual(got, want) {
+				t.Errorf("Factor() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache()
+	a := NewAnalyzer(WithCache(c))
+	a.Factor(syntaxRegexp(t, `(hello|world)x(hello|world)`))
+	n := c.Len()
+	if n == 0 {
+		t.Fatalf("empty cache")
+	}
+	// every sub expression has been already cached.
+	a.Factor(syntaxRegexp(t, `(hello|world)`))
+	if got := c.Len(); got != n {
+		t.Errorf("Len() = %v, want %v", got, n)
+	}
+	// the factors from the cache are not shared with callers.
+	f := a.Factor(syntaxRegexp(t, `hello`))
+	f.Add("aloha")
+	if got := a.Factor(syntaxRegexp(t, `hello`)); !reflect.DeepEqual(got, NewFactorLiteral("hello")) {
+		t.Errorf("Factor() = %v, want %v", got, NewFactorLiteral("hello"))
+	}
+}
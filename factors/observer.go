@@ -0,0 +1,49 @@
+package factors
+
+import (
+	"regexp/syntax"
+)
+
+// Observer observes the analysis of each node of a regexp.
+// An observer of an analyzer which analyzes patterns concurrently, e.g. by AnalyzeAll, must be safe for concurrent use.
+type Observer interface {
+	// Enter is called on entering a node before the analysis of its sub expressions.
+	Enter(re *syntax.Regexp)
+	// Leave is called on leaving a node with the result of the analysis.
+	Leave(re *syntax.Regexp, o *Observation)
+}
+
+// Observation represents a result of the analysis of a node.
+type Observation struct {
+	Op syntax.Op
+	// Factor is the factor of the node. An observer may replace it, e.g. with θ to limit the size of the sets,
+	// and the analysis of the enclosing nodes and the identical sub expressions use the replaced one.
+	// The replaced factors are not stored in the cache of the analyzer, which other analyzers may share.
+	Factor Factor
+	// Exact, Prefix, Suffix and Fragment are the number of the items of each set, -1 if the set is θ.
+	Exact, Prefix, Suffix, Fragment int
+	// Cached is true if the factor is reused from the cache, then the sub expressions are not visited.
+	Cached bool
+}
+
+// WithObserver sets an observer of the analysis.
+func WithObserver(o Observer) Option {
+	return func(a *Analyzer) {
+		a.observer = o
+	}
+}
+
+// observe calls the observer on leaving a node, and returns the factor which the observer may replace.
+func (m *memo) observe(re *syntax.Regexp, f Factor, cached bool) Factor {
+	o := Observation{
+		Op:       re.Op,
+		Factor:   f,
+		Exact:    f.Exact.Len(),
+		Prefix:   f.Prefix.Len(),
+		Suffix:   f.Suffix.Len(),
+		Fragment: f.Fragment.Len(),
+		Cached:   cached,
+	}
+	m.observer.Leave(re, &o)
+	return o.Factor
+}
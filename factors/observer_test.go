@@ -0,0 +1,133 @@
+package factors
+
+import (
+	"regexp/syntax"
+	"testing"
+)
+
+type recorder struct {
+	depth, maxDepth int
+	enter, leave    []syntax.Op
+	theta           map[syntax.Op]int
+	cached          int
+}
+
+func (r *recorder) Enter(re *syntax.Regexp) {
+	r.depth++
+	if r.depth > r.maxDepth {
+		r.maxDepth = r.depth
+	}
+	r.enter = append(r.enter, re.Op)
+}
+
+func (r *recorder) Leave(re *syntax.Regexp, o *Observation) {
+	r.depth--
+	r.leave = append(r.leave, o.Op)
+	if o.Fragment < 0 {
+		r.theta[o.Op]++
+	}
+	if o.Cached {
+		r.cached++
+	}
+}
+
+func TestWithObserver(t *testing.T) {
+	re, err := syntax.Parse(`(?:abc)+x*(?:abc)+`, syntax.Perl)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	r := &recorder{theta: map[syntax.Op]int{}}
+	NewAnalyzer(WithObserver(r), WithCache(NewCache())).Factor(re)
+	// The analysis of x* does not visit x.
+	wantEnter := []syntax.Op{syntax.OpConcat, syntax.OpPlus, syntax.OpLiteral, syntax.OpStar, syntax.OpPlus}
+	wantLeave := []syntax.Op{syntax.OpLiteral, syntax.OpPlus, syntax.OpStar, syntax.OpPlus, syntax.OpConcat}
+	if !equalOps(r.enter, wantEnter) {
+		t.Errorf("Enter = %v, want %v", r.enter, wantEnter)
+	}
+	if !equalOps(r.leave, wantLeave) {
+		t.Errorf("Leave = %v, want %v", r.leave, wantLeave)
+	}
+	if r.depth != 0 || r.maxDepth != 3 {
+		t.Errorf("depth = %d, max depth = %d, want 0, 3", r.depth, r.maxDepth)
+	}
+	if r.theta[syntax.OpStar] != 1 || len(r.theta) != 1 {
+		t.Errorf("θ = %v, want only one star", r.theta)
+	}
+	// The second (?:abc)+ reuses the factor of the first one.
+	if r.cached != 1 {
+		t.Errorf("cached = %d, want 1", r.cached)
+	}
+}
+
+func TestWithObserver_repeat(t *testing.T) {
+	re, err := syntax.Parse(`[a-c]{2,100}`, syntax.Perl)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	r := &recorder{theta: map[syntax.Op]int{}}
+	NewAnalyzer(WithObserver(r)).Factor(re)
+	// The repeat too large to expand visits the sub expression only once.
+	wantEnter := []syntax.Op{syntax.OpRepeat, syntax.OpCharClass}
+	if !equalOps(r.enter, wantEnter) {
+		t.Errorf("Enter = %v, want %v", r.enter, wantEnter)
+	}
+}
+
+type limiter struct {
+	limit int
+}
+
+func (l limiter) Enter(re *syntax.Regexp) {}
+
+func (l limiter) Leave(re *syntax.Regexp, o *Observation) {
+	if o.Exact > l.limit {
+		o.Factor.Exact.SetInfinite()
+	}
+}
+
+func TestWithObserver_limit(t *testing.T) {
+	re, err := syntax.Parse(`[a-z]x[0-9]`, syntax.Perl)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	got := NewAnalyzer(WithObserver(limiter{limit: 100})).Factor(re)
+	want := NewAnalyzer().Factor(re)
+	if got.Exact.Len() != -1 || want.Exact.Len() != 260 {
+		t.Errorf("Exact = %v, want θ instead of %v", got.Exact, want.Exact)
+	}
+	if !got.Fragment.Equal(want.Fragment) {
+		t.Errorf("Fragment = %v, want %v", got.Fragment, want.Fragment)
+	}
+}
+
+func TestWithObserver_cache(t *testing.T) {
+	re, err := syntax.Parse(`[a-z]x[0-9]`, syntax.Perl)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	want := NewAnalyzer().Factor(re)
+	c := NewCache()
+	observed := NewAnalyzer(WithCache(c), WithObserver(limiter{limit: 100}))
+	plain := NewAnalyzer(WithCache(c))
+	// The replaced factors of the observed analysis do not leak into the plain one, and vice versa.
+	for i := 0; i < 2; i++ {
+		if got := observed.Factor(re); got.Exact.Len() != -1 {
+			t.Errorf("observed Exact = %v, want θ", got.Exact)
+		}
+		if got := plain.Factor(re); !got.Exact.Equal(want.Exact) {
+			t.Errorf("plain Exact = %v, want %v", got.Exact, want.Exact)
+		}
+	}
+}
+
+func equalOps(x, y []syntax.Op) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,383 @@
+package factors
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseWithSpans parses a pattern (with syntax.Perl flags) keeping the spans of the sub expressions.
+// syntax.Regexp has no positions, so it parses the structure of the pattern, i.e. groups, alternations,
+// concatenations and repetitions, by itself, and leaves the atoms, e.g. a character class or an escape, to syntax.Parse.
+// Unlike syntax.Parse, it does not simplify the parse tree except for joining adjacent literals.
+func parseWithSpans(pattern string) (*syntax.Regexp, map[*syntax.Regexp]Span, error) {
+	// Report the errors as the regexp package does.
+	if _, err := syntax.Parse(pattern, syntax.Perl); err != nil {
+		return nil, nil, err
+	}
+	p := spanParser{
+		src:   pattern,
+		flags: syntax.Perl,
+		spans: map[*syntax.Regexp]Span{},
+	}
+	re, err := p.parseAlternate()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, nil, fmt.Errorf("unexpected %q at %d", p.src[p.pos], p.pos)
+	}
+	return re, p.spans, nil
+}
+
+type spanParser struct {
+	src   string
+	pos   int
+	flags syntax.Flags
+	ncap  int
+	spans map[*syntax.Regexp]Span
+}
+
+func (p *spanParser) node(re *syntax.Regexp, start int) *syntax.Regexp {
+	p.spans[re] = Span{Start: start, End: p.pos}
+	return re
+}
+
+func (p *spanParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *spanParser) parseAlternate() (*syntax.Regexp, error) {
+	start := p.pos
+	re, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	subs := []*syntax.Regexp{re}
+	for p.pos < len(p.src) && p.peek() == '|' {
+		p.pos++
+		re, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, re)
+	}
+	if len(subs) == 1 {
+		return subs[0], nil
+	}
+	return p.node(&syntax.Regexp{Op: syntax.OpAlternate, Flags: p.flags, Sub: subs}, start), nil
+}
+
+func (p *spanParser) parseConcat() (*syntax.Regexp, error) {
+	start := p.pos
+	var subs []*syntax.Regexp
+	for p.pos < len(p.src) && p.peek() != '|' && p.peek() != ')' {
+		re, err := p.parseRepeat()
+		if err != nil {
+			return nil, err
+		}
+		if re == nil {
+			continue
+		}
+		// Join adjacent literals as syntax.Parse does.
+		if n := len(subs); n > 0 && re.Op == syntax.OpLiteral && subs[n-1].Op == syntax.OpLiteral &&
+			re.Flags&syntax.FoldCase == subs[n-1].Flags&syntax.FoldCase {
+			last := subs[n-1]
+			joined := &syntax.Regexp{Op: syntax.OpLiteral, Flags: last.Flags}
+			joined.Rune = append(append(joined.Rune, last.Rune...), re.Rune...)
+			p.spans[joined] = Span{Start: p.spans[last].Start, End: p.spans[re].End}
+			subs[n-1] = joined
+			continue
+		}
+		subs = append(subs, re)
+	}
+	switch len(subs) {
+	case 0:
+		return p.node(&syntax.Regexp{Op: syntax.OpEmptyMatch, Flags: p.flags}, start), nil
+	case 1:
+		return subs[0], nil
+	}
+	return p.node(&syntax.Regexp{Op: syntax.OpConcat, Flags: p.flags, Sub: subs}, start), nil
+}
+
+// parseRepeat parses an atom and its repetitions, it returns nil for a group which only sets flags.
+func (p *spanParser) parseRepeat() (*syntax.Regexp, error) {
+	start := p.pos
+	re, err := p.parseAtom()
+	if err != nil || re == nil {
+		return nil, err
+	}
+	for p.pos < len(p.src) {
+		rep := &syntax.Regexp{Flags: p.flags, Sub: []*syntax.Regexp{re}}
+		switch p.peek() {
+		case '*':
+			rep.Op = syntax.OpStar
+			p.pos++
+		case '+':
+			rep.Op = syntax.OpPlus
+			p.pos++
+		case '?':
+			rep.Op = syntax.OpQuest
+			p.pos++
+		case '{':
+			from, to, size, ok := parseRepeatRange(p.src[p.pos:])
+			if !ok {
+				return re, nil
+			}
+			rep.Op = syntax.OpRepeat
+			rep.Min, rep.Max = from, to
+			p.pos += size
+		default:
+			return re, nil
+		}
+		// non-greedy, or greedy under (?U).
+		if p.peek() == '?' {
+			rep.Flags ^= syntax.NonGreedy
+			p.pos++
+		}
+		re = p.node(rep, start)
+	}
+	return re, nil
+}
+
+// parseRepeatRange parses {n}, {n,} or {n,m} at the head of s, and returns the size of it.
+func parseRepeatRange(s string) (from, to, size int, ok bool) {
+	end := strings.IndexByte(s, '}')
+	if end < 0 {
+		return 0, 0, 0, false
+	}
+	body := s[1:end]
+	lo, hi := body, body
+	if i := strings.IndexByte(body, ','); i >= 0 {
+		lo, hi = body[:i], body[i+1:]
+	}
+	from, err := strconv.Atoi(lo)
+	if err != nil || lo == "" || lo[0] == '+' || lo[0] == '-' {
+		return 0, 0, 0, false
+	}
+	to = from
+	if hi != body {
+		to = -1
+		if hi != "" {
+			if to, err = strconv.Atoi(hi); err != nil || hi[0] == '+' || hi[0] == '-' {
+				return 0, 0, 0, false
+			}
+		}
+	}
+	return from, to, end + 1, true
+}
+
+func (p *spanParser) parseAtom() (*syntax.Regexp, error) {
+	start := p.pos
+	switch p.peek() {
+	case '(':
+		return p.parseGroup()
+	case '[':
+		end, err := classEnd(p.src, p.pos)
+		if err != nil {
+			return nil, err
+		}
+		return p.leaf(start, end)
+	case '\\':
+		return p.leaf(start, escapeEnd(p.src, p.pos))
+	}
+	_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	return p.leaf(start, p.pos+size)
+}
+
+func (p *spanParser) parseGroup() (*syntax.Regexp, error) {
+	start := p.pos
+	p.pos++ // (
+	saved := p.flags
+	defer func() {
+		p.flags = saved
+	}()
+	var name string
+	capture := true
+	if strings.HasPrefix(p.src[p.pos:], "?") {
+		rest := p.src[p.pos:]
+		switch {
+		case strings.HasPrefix(rest, "?P<") || strings.HasPrefix(rest, "?<"):
+			begin := strings.IndexByte(rest, '<') + 1
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid named capture at %d", start)
+			}
+			name = rest[begin:end]
+			p.pos += end + 1
+		default:
+			// (?flags) or (?flags:re)
+			p.pos++
+			flags, err := p.parseFlags()
+			if err != nil {
+				return nil, err
+			}
+			if p.peek() == ')' {
+				p.pos++
+				// The flags are effective until the end of the enclosing group.
+				saved = flags
+				return nil, nil
+			}
+			p.pos++ // :
+			p.flags = flags
+			capture = false
+		}
+	}
+	var index int
+	if capture {
+		p.ncap++
+		index = p.ncap
+	}
+	re, err := p.parseAlternate()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != ')' {
+		return nil, fmt.Errorf("missing ) at %d", p.pos)
+	}
+	p.pos++
+	if !capture {
+		return re, nil
+	}
+	return p.node(&syntax.Regexp{Op: syntax.OpCapture, Flags: saved, Cap: index, Name: name, Sub: []*syntax.Regexp{re}}, start), nil
+}
+
+// parseFlags parses flags like i-s of (?i-s) or (?i-s:re), and stops at ) or :.
+func (p *spanParser) parseFlags() (syntax.Flags, error) {
+	flags := p.flags
+	negate := false
+	for p.pos < len(p.src) {
+		var f syntax.Flags
+		switch c := p.peek(); c {
+		case ')', ':':
+			return flags, nil
+		case '-':
+			negate = true
+			p.pos++
+			continue
+		case 'i':
+			f = syntax.FoldCase
+		case 's':
+			f = syntax.DotNL
+		case 'm':
+			// (?m) is the negation of syntax.OneLine.
+			if negate {
+				flags |= syntax.OneLine
+			} else {
+				flags &^= syntax.OneLine
+			}
+			p.pos++
+			continue
+		case 'U':
+			f = syntax.NonGreedy
+		default:
+			return 0, fmt.Errorf("invalid flag %q at %d", c, p.pos)
+		}
+		if negate {
+			flags &^= f
+		} else {
+			flags |= f
+		}
+		p.pos++
+	}
+	return 0, fmt.Errorf("missing ) at %d", p.pos)
+}
+
+// leaf parses an atom of the pattern[start:end] with syntax.Parse under the current flags.
+func (p *spanParser) leaf(start, end int) (*syntax.Regexp, error) {
+	var prefix string
+	if p.flags&syntax.FoldCase != 0 {
+		prefix += "i"
+	}
+	if p.flags&syntax.DotNL != 0 {
+		prefix += "s"
+	}
+	if p.flags&syntax.OneLine == 0 {
+		prefix += "m"
+	}
+	if prefix != "" {
+		prefix = "(?" + prefix + ")"
+	}
+	re, err := syntax.Parse(prefix+p.src[start:end], syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	p.pos = end
+	return p.node(re, start), nil
+}
+
+// classEnd returns the end of a character class which begins at i.
+func classEnd(s string, i int) (int, error) {
+	start := i
+	i++ // [
+	if i < len(s) && s[i] == '^' {
+		i++
+	}
+	// ] at the head is a literal.
+	if i < len(s) && s[i] == ']' {
+		i++
+	}
+	for i < len(s) {
+		switch {
+		case s[i] == ']':
+			return i + 1, nil
+		case s[i] == '\\':
+			i = escapeEnd(s, i)
+		case strings.HasPrefix(s[i:], "[:"):
+			if end := strings.Index(s[i+2:], ":]"); end >= 0 {
+				i += end + 4
+				continue
+			}
+			i++
+		default:
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+		}
+	}
+	return 0, fmt.Errorf("missing ] of the class at %d", start)
+}
+
+// escapeEnd returns the end of an escape sequence which begins at i.
+func escapeEnd(s string, i int) int {
+	i++ // \
+	if i >= len(s) {
+		return i
+	}
+	switch c := s[i]; {
+	case c == 'Q':
+		if end := strings.Index(s[i:], `\E`); end >= 0 {
+			return i + end + 2
+		}
+		return len(s)
+	case c == 'x' || c == 'p' || c == 'P':
+		return argumentEnd(s, i)
+	case '0' <= c && c <= '7':
+		j := i + 1
+		for j < len(s) && j < i+3 && '0' <= s[j] && s[j] <= '7' {
+			j++
+		}
+		return j
+	}
+	_, size := utf8.DecodeRuneInString(s[i:])
+	return i + size
+}
+
+// argumentEnd returns the end of an escape sequence \x, \p or \P whose letter is at i,
+// i.e. with the argument in braces, two hex digits of \x or a letter of \p and \P.
+func argumentEnd(s string, i int) int {
+	if i+1 < len(s) && s[i+1] == '{' {
+		if end := strings.IndexByte(s[i:], '}'); end >= 0 {
+			return i + end + 1
+		}
+		return len(s)
+	}
+	if s[i] == 'x' {
+		return minInt(i+3, len(s))
+	}
+	return minInt(i+2, len(s))
+}
@@ -0,0 +1,203 @@
+package factors
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ikawaha/factors/factors/ahocorasick"
+)
+
+// PatternSet matches many regexps against a text at once.
+// It finds the literals of the queries of all the regexps with one automaton,
+// and runs only the regexps whose queries the found literals satisfy, and the regexps without queries, e.g. θ,
+// or with empty width assertions.
+// It is never modified after it is built, so it is safe for concurrent use.
+type PatternSet struct {
+	regexps []*regexp.Regexp
+	queries []*Query
+	// ac finds the literals, and literals[i] is the literal of the pattern i of ac.
+	ac       *ahocorasick.Automaton
+	literals []string
+	ids      map[string]int
+	// candidates[i] is the regexps whose queries have the literal i.
+	candidates [][]int
+	// always is the regexps which run for every text, e.g. the queries are θ or the regexps have empty width assertions.
+	always []int
+	// invalid is the regexps which may match an invalid UTF-8 sequence as U+FFFD,
+	// they run for every text which is not valid UTF-8.
+	invalid []int
+	pool    sync.Pool
+}
+
+// PatternMatch represents the leftmost match of a regexp of a pattern set.
+type PatternMatch struct {
+	// ID is the index of the pattern.
+	ID int
+	// Start and End are the byte range [Start, End) of the match in the text.
+	Start, End int
+}
+
+// patternScratch is the working memory of a scan.
+type patternScratch struct {
+	found     []bool
+	hits      []int
+	candidate []bool
+}
+
+// NewPatternSet parses (with syntax.Perl flags) and analyzes the patterns, and returns a pattern set of them.
+// The IDs of the patterns are the indexes of the slice.
+func NewPatternSet(patterns []string) (*PatternSet, error) {
+	return NewAnalyzer(WithCache(NewCache())).PatternSet(patterns)
+}
+
+// PatternSet analyzes the patterns concurrently as AnalyzeAll does, and returns a pattern set of them.
+func (a Analyzer) PatternSet(patterns []string) (*PatternSet, error) {
+	ret := &PatternSet{
+		regexps: make([]*regexp.Regexp, len(patterns)),
+		queries: make([]*Query, len(patterns)),
+		ids:     map[string]int{},
+	}
+	for i, v := range a.AnalyzeAll(context.Background(), patterns) {
+		if v.Err != nil {
+			return nil, fmt.Errorf("pattern %d: %v", i, v.Err)
+		}
+		re, err := regexp.Compile(v.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %d: %v", i, err)
+		}
+		sre, err := syntax.Parse(v.Pattern, syntax.Perl)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %d: %v", i, err)
+		}
+		ret.regexps[i] = re
+		ret.queries[i] = v.Factor.Query()
+		if hasRuneError(sre) {
+			ret.invalid = append(ret.invalid, i)
+		}
+		// The factors of a regexp with empty width assertions, e.g. `^$|x`, may miss the matches of the assertions.
+		if ret.queries[i].Op == QAll || hasEmptyWidth(sre) {
+			ret.always = append(ret.always, i)
+			continue
+		}
+		for _, l := range queryLiterals(ret.queries[i]) {
+			id, ok := ret.ids[l]
+			if !ok {
+				id = len(ret.literals)
+				ret.ids[l] = id
+				ret.literals = append(ret.literals, l)
+				ret.candidates = append(ret.candidates, nil)
+			}
+			ret.candidates[id] = append(ret.candidates[id], i)
+		}
+	}
+	ret.ac = ahocorasick.New(ret.literals)
+	ret.pool.New = func() interface{} {
+		return &patternScratch{
+			found:     make([]bool, len(ret.literals)),
+			candidate: make([]bool, len(patterns)),
+		}
+	}
+	return ret, nil
+}
+
+// Len returns the number of the patterns.
+func (s *PatternSet) Len() int {
+	return len(s.regexps)
+}
+
+// Match returns the sorted IDs of the patterns which match the text.
+func (s *PatternSet) Match(b []byte) []int {
+	var ret []int
+	s.each(b, utf8.Valid, func(id int) {
+		if s.regexps[id].Match(b) {
+			ret = append(ret, id)
+		}
+	})
+	return ret
+}
+
+// MatchString returns the sorted IDs of the patterns which match the text.
+func (s *PatternSet) MatchString(str string) []int {
+	var ret []int
+	s.each([]byte(str), utf8.Valid, func(id int) {
+		if s.regexps[id].MatchString(str) {
+			ret = append(ret, id)
+		}
+	})
+	return ret
+}
+
+// FindIndex returns the leftmost matches of the patterns which match the text in the order of the IDs.
+func (s *PatternSet) FindIndex(b []byte) []PatternMatch {
+	var ret []PatternMatch
+	s.each(b, utf8.Valid, func(id int) {
+		if loc := s.regexps[id].FindIndex(b); loc != nil {
+			ret = append(ret, PatternMatch{ID: id, Start: loc[0], End: loc[1]})
+		}
+	})
+	return ret
+}
+
+// Candidates returns the sorted IDs of the patterns which the pattern set verifies with the regexps for the text.
+func (s *PatternSet) Candidates(b []byte) []int {
+	var ret []int
+	s.each(b, utf8.Valid, func(id int) {
+		ret = append(ret, id)
+	})
+	return ret
+}
+
+// each calls fn for the candidate patterns of the text in the order of the IDs.
+func (s *PatternSet) each(b []byte, valid func([]byte) bool, fn func(id int)) {
+	scratch := s.pool.Get().(*patternScratch)
+	defer s.pool.Put(scratch)
+	s.ac.Overlapping(b, func(m ahocorasick.Match) bool {
+		if !scratch.found[m.Pattern] {
+			scratch.found[m.Pattern] = true
+			scratch.hits = append(scratch.hits, m.Pattern)
+		}
+		return true
+	})
+	var ids []int
+	mark := func(id int) {
+		if !scratch.candidate[id] {
+			scratch.candidate[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range s.always {
+		mark(id)
+	}
+	if len(s.invalid) > 0 && !valid(b) {
+		for _, id := range s.invalid {
+			mark(id)
+		}
+	}
+	has := func(literal string) bool {
+		return scratch.found[s.ids[literal]]
+	}
+	for _, l := range scratch.hits {
+		for _, id := range s.candidates[l] {
+			if !scratch.candidate[id] && s.queries[id].Eval(has) {
+				mark(id)
+			}
+		}
+	}
+	// Reset the scratch for the next scan.
+	for _, l := range scratch.hits {
+		scratch.found[l] = false
+	}
+	scratch.hits = scratch.hits[:0]
+	for _, id := range ids {
+		scratch.candidate[id] = false
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		fn(id)
+	}
+}
@@ -0,0 +1,122 @@
+package factors
+
+import (
+	"math/rand"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestPatternSet(t *testing.T) {
+	patterns := []string{
+		`abc`,
+		`a(b|c)d`,
+		`(?i)ab.c`,
+		`a[bc]{1,3}d`,
+		`(ab|cd)e?`,
+		`.b.`,
+		`a+b`,
+		`\bab`,
+		`\x{FFFD}b`,
+		`x(a|ab)(c|bcd)`,
+		`^ab$`,
+		`e`,
+		`^$|x`,
+		`(?:$){2}|x`,
+		`(?:^|é)(?:^|é)`,
+		`\bfoo\b|^$`,
+	}
+	s, err := NewPatternSet(patterns)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if got, want := s.Len(), len(patterns); got != want {
+		t.Errorf("Len() = %d, want %d", got, want)
+	}
+	var res []*regexp.Regexp
+	for _, v := range patterns {
+		res = append(res, regexp.MustCompile(v))
+	}
+	r := rand.New(rand.NewSource(1))
+	texts := []string{"", "ab", "ああbい", "xabcd", "ABxC abcc", "x", "é", "a", "foo", "a foo"}
+	for i := 0; i < 300; i++ {
+		b := make([]byte, r.Intn(40))
+		for j := range b {
+			b[j] = "abcdex \xff"[r.Intn(8)]
+		}
+		texts = append(texts, string(b))
+	}
+	for _, text := range texts {
+		var ids []int
+		var matches []PatternMatch
+		for i, re := range res {
+			if loc := re.FindStringIndex(text); loc != nil {
+				ids = append(ids, i)
+				matches = append(matches, PatternMatch{ID: i, Start: loc[0], End: loc[1]})
+			}
+		}
+		if got := s.Match([]byte(text)); !reflect.DeepEqual(got, ids) {
+			t.Errorf("Match(%q) = %v, want %v", text, got, ids)
+		}
+		if got := s.MatchString(text); !reflect.DeepEqual(got, ids) {
+			t.Errorf("MatchString(%q) = %v, want %v", text, got, ids)
+		}
+		if got := s.FindIndex([]byte(text)); !reflect.DeepEqual(got, matches) {
+			t.Errorf("FindIndex(%q) = %v, want %v", text, got, matches)
+		}
+	}
+	if _, err := NewPatternSet([]string{`a`, `a(`}); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestPatternSet_Candidates(t *testing.T) {
+	s, err := NewPatternSet([]string{`abc`, `x.*y`, `.+`, `(foo|bar)baz`, `^$|xyz`})
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{text: "", want: []int{2, 4}},
+		{text: "abc", want: []int{0, 2, 4}},
+		{text: "x y", want: []int{1, 2, 4}},
+		{text: "barbaz", want: []int{2, 3, 4}},
+		{text: "bazbar", want: []int{2, 4}},
+		{text: "baz", want: []int{2, 4}},
+	}
+	for _, tt := range tests {
+		if got := s.Candidates([]byte(tt.text)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Candidates(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPatternSet_Concurrent(t *testing.T) {
+	s, err := NewPatternSet([]string{`abc`, `b+c`, `(x|y)z`})
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	texts := map[string][]int{
+		"abc": {0, 1},
+		"yz":  {2},
+		"bbb": nil,
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for text, want := range texts {
+					if got := s.MatchString(text); !reflect.DeepEqual(got, want) {
+						t.Errorf("MatchString(%q) = %v, want %v", text, got, want)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
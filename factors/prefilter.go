@@ -0,0 +1,257 @@
+package factors
+
+import (
+	"bytes"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ikawaha/factors/factors/ahocorasick"
+)
+
+// Prefilter rejects texts which the regexp never matches by the necessary factors,
+// it is faster than running the regexp, so it runs before the regexp.
+// It never rejects a text which the regexp matches, but it may accept a text which the regexp does not match.
+type Prefilter struct {
+	// exact, prefix and suffix are the items the text must be, begin with and end with,
+	// nil if the regexp is not anchored or the set has no requirement.
+	exact  map[string]bool
+	prefix []string
+	suffix []string
+	// query is the literals which the text must contain.
+	query *Query
+	// literal is the only literal of the query, nil if the query has more literals.
+	literal []byte
+	// ac finds the literals of the query with the ids of ids.
+	ac  *ahocorasick.Automaton
+	ids map[string]int
+	// first is true if one of the literals is enough.
+	first bool
+	// invalid is true if the regexp may match an invalid UTF-8 sequence as U+FFFD,
+	// which the literals do not contain.
+	invalid bool
+}
+
+// NewPrefilter parses (with syntax.Perl flags) a given pattern and returns a prefilter of it.
+func NewPrefilter(pattern string) (*Prefilter, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	return NewAnalyzer().Prefilter(re), nil
+}
+
+// Prefilter returns a prefilter of a given regexp.
+// If the regexp is anchored at the beginning (or the end) of the text,
+// the prefilter checks that the text begins (or ends) with an item of the prefix (or suffix) set,
+// or that the text is an item of the exact set if both.
+// Then it searches the text for the literals of the query of the factor,
+// with bytes.Index for a single literal or with an Aho-Corasick automaton for multiple literals.
+func (a Analyzer) Prefilter(re *syntax.Regexp) *Prefilter {
+	f := a.Factor(re)
+	ret := &Prefilter{query: f.Query(), invalid: hasRuneError(re)}
+	begin, end := anchors(re)
+	switch {
+	case begin && end && !f.Exact.infinite:
+		ret.exact = map[string]bool{}
+		for _, v := range f.Exact.list() {
+			ret.exact[v] = true
+		}
+	default:
+		if begin && !f.Prefix.infinite && !f.Prefix.Contains("") {
+			ret.prefix = f.Prefix.Items()
+		}
+		if end && !f.Suffix.infinite && !f.Suffix.Contains("") {
+			ret.suffix = f.Suffix.Items()
+		}
+	}
+	literals := queryLiterals(ret.query)
+	switch {
+	case len(literals) == 1:
+		ret.literal = []byte(literals[0])
+	case len(literals) > 1:
+		ret.ac = ahocorasick.New(literals)
+		ret.ids = make(map[string]int, len(literals))
+		for i, v := range literals {
+			ret.ids[v] = i
+		}
+		ret.first = ret.query.Op == QOr && len(ret.query.Sub) == 0
+	}
+	return ret
+}
+
+// MayMatch returns false if the regexp never matches the text.
+func (p *Prefilter) MayMatch(b []byte) bool {
+	if p.invalid && !utf8.Valid(b) {
+		return true
+	}
+	if p.query.Op == QNone {
+		return false
+	}
+	if p.exact != nil && !p.exact[string(b)] {
+		return false
+	}
+	if p.prefix != nil && !hasAny(p.prefix, func(v string) bool { return len(v) <= len(b) && string(b[:len(v)]) == v }) {
+		return false
+	}
+	if p.suffix != nil && !hasAny(p.suffix, func(v string) bool { return len(v) <= len(b) && string(b[len(b)-len(v):]) == v }) {
+		return false
+	}
+	switch {
+	case p.literal != nil:
+		return bytes.Contains(b, p.literal)
+	case p.ac != nil:
+		return p.eval(func(fn func(m ahocorasick.Match) bool) { p.ac.Overlapping(b, fn) })
+	}
+	return true
+}
+
+// MayMatchString returns false if the regexp never matches the text.
+func (p *Prefilter) MayMatchString(s string) bool {
+	if p.invalid && !utf8.ValidString(s) {
+		return true
+	}
+	if p.query.Op == QNone {
+		return false
+	}
+	if p.exact != nil && !p.exact[s] {
+		return false
+	}
+	if p.prefix != nil && !hasAny(p.prefix, func(v string) bool { return strings.HasPrefix(s, v) }) {
+		return false
+	}
+	if p.suffix != nil && !hasAny(p.suffix, func(v string) bool { return strings.HasSuffix(s, v) }) {
+		return false
+	}
+	switch {
+	case p.literal != nil:
+		return strings.Contains(s, string(p.literal))
+	case p.ac != nil:
+		return p.eval(func(fn func(m ahocorasick.Match) bool) { p.ac.OverlappingString(s, fn) })
+	}
+	return true
+}
+
+// String returns string representation of the checks of the prefilter.
+func (p *Prefilter) String() string {
+	var ss []string
+	switch {
+	case p.exact != nil:
+		ss = append(ss, "exact "+NewSet(keys(p.exact)...).String())
+	default:
+		if p.prefix != nil {
+			ss = append(ss, "prefix "+NewSet(p.prefix...).String())
+		}
+		if p.suffix != nil {
+			ss = append(ss, "suffix "+NewSet(p.suffix...).String())
+		}
+	}
+	switch {
+	case p.query.Op == QNone:
+		return "none"
+	case p.literal != nil:
+		ss = append(ss, "index "+strconv.Quote(string(p.literal)))
+	case p.ac != nil:
+		ss = append(ss, "literals "+p.query.String())
+	}
+	if len(ss) == 0 {
+		return "all"
+	}
+	return strings.Join(ss, " AND ")
+}
+
+// eval scans the text for the literals with a given scan and evaluates the query.
+func (p *Prefilter) eval(scan func(fn func(m ahocorasick.Match) bool)) bool {
+	found := make([]bool, len(p.ids))
+	var n int
+	scan(func(m ahocorasick.Match) bool {
+		if !found[m.Pattern] {
+			found[m.Pattern] = true
+			n++
+		}
+		return !p.first && n < len(found)
+	})
+	if p.first {
+		return n > 0
+	}
+	return p.query.Eval(func(literal string) bool {
+		return found[p.ids[literal]]
+	})
+}
+
+// anchors returns whether the regexp is anchored at the beginning and the end of the text.
+func anchors(re *syntax.Regexp) (begin, end bool) {
+	for re.Op == syntax.OpCapture {
+		re = re.Sub[0]
+	}
+	switch re.Op {
+	case syntax.OpBeginText:
+		return true, false
+	case syntax.OpEndText:
+		return false, true
+	case syntax.OpConcat:
+		if len(re.Sub) == 0 {
+			return false, false
+		}
+		begin, _ = anchors(re.Sub[0])
+		_, end = anchors(re.Sub[len(re.Sub)-1])
+		return begin, end
+	}
+	return false, false
+}
+
+// hasRuneError returns true if the regexp has a literal or a character class which matches U+FFFD.
+func hasRuneError(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == utf8.RuneError {
+				return true
+			}
+		}
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= utf8.RuneError && utf8.RuneError <= re.Rune[i+1] {
+				return true
+			}
+		}
+	}
+	for _, v := range re.Sub {
+		if hasRuneError(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryLiterals returns the sorted literals of the query without duplicates.
+func queryLiterals(q *Query) []string {
+	var ret []string
+	var walk func(q *Query)
+	walk = func(q *Query) {
+		ret = append(ret, q.Literal...)
+		for _, v := range q.Sub {
+			walk(v)
+		}
+	}
+	walk(q)
+	return cleanStrings(ret, false)
+}
+
+func hasAny(items []string, has func(v string) bool) bool {
+	for _, v := range items {
+		if has(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func keys(m map[string]bool) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	return ret
+}
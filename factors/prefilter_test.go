@@ -0,0 +1,110 @@
+package factors
+
+import (
+	"math/rand"
+	"regexp"
+	"testing"
+)
+
+func TestNewPrefilter(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: `abc`, want: `index "abc"`},
+		{pattern: `abc|def`, want: `literals ("abc"|"def")`},
+		{pattern: `abc.*(def|ghi)`, want: `literals "abc" ("def"|"ghi")`},
+		{pattern: `^abc`, want: `prefix {abc} AND index "abc"`},
+		{pattern: `(abc|de)$`, want: `suffix {abc, de} AND literals ("abc"|"de")`},
+		{pattern: `^(ab|cd)$`, want: `exact {ab, cd} AND literals ("ab"|"cd")`},
+		{pattern: `.*`, want: `all`},
+		{pattern: `^.*`, want: `all`},
+	}
+	for _, tt := range tests {
+		p, err := NewPrefilter(tt.pattern)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		if got := p.String(); got != tt.want {
+			t.Errorf("NewPrefilter(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+	if _, err := NewPrefilter(`a(`); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestPrefilter_MayMatch(t *testing.T) {
+	patterns := []string{
+		`abc`,
+		`abc|bcd`,
+		`ab.*c(d|e)`,
+		`^ab`,
+		`c\d$`,
+		`^(ab|c+)$`,
+		`a(b|c)*d`,
+		`(?i)ab`,
+		`^$`,
+		`a+b+`,
+		`(ab)?`,
+		`\bab\b`,
+		`(?m)^b`,
+		`\x{FFFD}b`,
+		`^$|x`,
+		`(?:$){2}|x`,
+		`(?:^|é)(?:^|é)`,
+		`\bfoo\b|^$`,
+	}
+	// The patterns match every text, or some empty text.
+	rejectsNothing := map[string]bool{
+		`(ab)?`:          true,
+		`^$|x`:           true,
+		`(?:$){2}|x`:     true,
+		`(?:^|é)(?:^|é)`: true,
+		`\bfoo\b|^$`:     true,
+	}
+	r := rand.New(rand.NewSource(1))
+	texts := []string{"", "abc", "ab", "xab", "c1", "abcd", "bcd", "ad", "AB", "b\nb", "\xffb", "a", "é", "x", "foo", "a foo"}
+	for i := 0; i < 1000; i++ {
+		b := make([]byte, r.Intn(8))
+		for j := range b {
+			b[j] = "abcde1\n"[r.Intn(7)]
+		}
+		texts = append(texts, string(b))
+	}
+	for _, pattern := range patterns {
+		re := regexp.MustCompile(pattern)
+		p, err := NewPrefilter(pattern)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		var rejected int
+		for _, text := range texts {
+			got, gotString := p.MayMatch([]byte(text)), p.MayMatchString(text)
+			if got != gotString {
+				t.Errorf("%s: MayMatch(%q) = %v, MayMatchString() = %v", pattern, text, got, gotString)
+			}
+			if !got {
+				rejected++
+			}
+			if re.MatchString(text) && !got {
+				t.Errorf("%s (%v): MayMatch(%q) rejects a match", pattern, p, text)
+			}
+		}
+		if !rejectsNothing[pattern] && rejected == 0 {
+			t.Errorf("%s (%v): rejects nothing", pattern, p)
+		}
+	}
+}
+
+func BenchmarkPrefilter_MayMatch(b *testing.B) {
+	p, err := NewPrefilter(`(error|warning|fatal): .* not found`)
+	if err != nil {
+		b.Fatalf("unexpected error, %v", err)
+	}
+	text := []byte("2021/01/01 00:00:00 info: the file is found in the directory, and the request is processed")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.MayMatch(text)
+	}
+}
@@ -0,0 +1,542 @@
+// The query algebra of this file, i.e. Query, String, andOr, implies, literalsImply, unionStrings,
+// isSubsetOfStrings and cleanStrings, is derived from index/regexp.go of Google Code Search
+// (https://github.com/google/codesearch), which is distributed under the following notice:
+//
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.codesearch file.
+
+package factors
+
+import (
+	"sort"
+	"strconv"
+)
+
+// QueryOp is an operator of a query.
+type QueryOp int
+
+const (
+	// QAll matches everything.
+	QAll QueryOp = iota
+	// QNone matches nothing.
+	QNone
+	// QAnd requires all the literals and the sub queries.
+	QAnd
+	// QOr requires one of the literals or the sub queries.
+	QOr
+)
+
+// Query represents a boolean query over literals.
+type Query struct {
+	Op      QueryOp
+	Literal []string // sorted, without duplicates.
+	Sub     []*Query
+}
+
+var (
+	allQuery  = &Query{Op: QAll}
+	noneQuery = &Query{Op: QNone}
+)
+
+// AllQuery returns the query which matches everything.
+func AllQuery() *Query {
+	return allQuery
+}
+
+// NoneQuery returns the query which matches nothing.
+func NoneQuery() *Query {
+	return noneQuery
+}
+
+// LiteralQuery returns the query which requires a literal.
+func LiteralQuery(literal string) *Query {
+	return &Query{Op: QAnd, Literal: []string{literal}}
+}
+
+// Query returns the query every text containing a match satisfies,
+// i.e. the text contains an item of each set of the factor.
+// A set implied by another set, e.g. the prefix set which has the exact set in it, is omitted.
+func (f Factor) Query() *Query {
+	return factorQuery(f)
+}
+
+// factorQuery returns the query every text containing a match satisfies,
+// i.e. the text contains an item of each set of the factor.
+func factorQuery(f Factor) *Query {
+	sets := []Set{f.Exact, f.Prefix, f.Suffix, f.Fragment}
+	q := allQuery
+loop:
+	for i, s := range sets {
+		// A text which contains an item of the other set also contains an item of s.
+		for j, t := range sets {
+			if i != j && !t.infinite && !s.infinite && everyHasFragment(t, s) && (j < i || !everyHasFragment(s, t)) {
+				continue loop
+			}
+		}
+		q = q.and(setQuery(s))
+	}
+	return q
+}
+
+// setQuery returns the query which requires one of the items of the set.
+func setQuery(s Set) *Query {
+	switch {
+	case s.infinite || s.Contains(""):
+		return allQuery
+	case s.size() == 0:
+		return noneQuery
+	}
+	return &Query{Op: QOr, Literal: s.Items()}
+}
+
+// String returns string representation of a query.
+// e.g. `"abc" ("def"|"ghi")`, "+" (all) and "-" (none).
+func (q *Query) String() string {
+	if q == nil {
+		return "?"
+	}
+	switch q.Op {
+	case QNone:
+		return "-"
+	case QAll:
+		return "+"
+	}
+	if len(q.Sub) == 0 && len(q.Literal) == 1 {
+		return strconv.Quote(q.Literal[0])
+	}
+	var s, sjoin, end, tjoin string
+	if q.Op == QAnd {
+		sjoin = " "
+		tjoin = " "
+	} else {
+		s = "("
+		sjoin = ")|("
+		end = ")"
+		tjoin = "|"
+	}
+	for i, t := range q.Literal {
+		if i > 0 {
+			s += tjoin
+		}
+		s += strconv.Quote(t)
+	}
+	if len(q.Sub) > 0 {
+		if len(q.Literal) > 0 {
+			s += sjoin
+		}
+		s += q.Sub[0].String()
+		for i := 1; i < len(q.Sub); i++ {
+			s += sjoin + q.Sub[i].String()
+		}
+	}
+	s += end
+	return s
+}
+
+// Eval evaluates the query with the results of the literals given by has.
+func (q *Query) Eval(has func(literal string) bool) bool {
+	switch q.Op {
+	case QAll:
+		return true
+	case QAnd:
+		for _, v := range q.Literal {
+			if !has(v) {
+				return false
+			}
+		}
+		for _, v := range q.Sub {
+			if !v.Eval(has) {
+				return false
+			}
+		}
+		return true
+	case QOr:
+		for _, v := range q.Literal {
+			if has(v) {
+				return true
+			}
+		}
+		for _, v := range q.Sub {
+			if v.Eval(has) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// And returns q AND r with boolean simplification. It never modifies q and r.
+func (q *Query) And(r *Query) *Query {
+	return q.and(r)
+}
+
+// Or returns q OR r with boolean simplification. It never modifies q and r.
+func (q *Query) Or(r *Query) *Query {
+	return q.or(r)
+}
+
+// Simplify returns an equivalent query in the canonical form, e.g. to simplify a query built by hand.
+// It flattens nested operators of the same kind, removes duplicates, drops the operands absorbed by others,
+// factors out common literals and sorts the operands, so the string representations of
+// the simplified queries are equal if they are built from the same operands in any order.
+func (q *Query) Simplify() *Query {
+	for {
+		r := q.simplify()
+		if r.String() == q.String() {
+			return r
+		}
+		q = r
+	}
+}
+
+// simplify is a pass of Simplify. It combines the operands in the order of their string representations
+// after flattening, deduplication and absorption, so the result does not depend on the order of the operands.
+func (q *Query) simplify() *Query {
+	if q.Op != QAnd && q.Op != QOr {
+		return q
+	}
+	ops := q.operands(nil, q.Op)
+	keys := make([]string, len(ops))
+	for i, v := range ops {
+		keys[i] = v.String()
+	}
+	sort.Sort(queriesByKey{queries: ops, keys: keys})
+	var w int
+	for i := range ops {
+		if i > 0 && keys[i] == keys[w-1] {
+			continue
+		}
+		ops[w], keys[w] = ops[i], keys[i]
+		w++
+	}
+	ops = ops[:w]
+	// Start from the identity of the operator.
+	ret := allQuery
+	if q.Op == QOr {
+		ret = noneQuery
+	}
+	for i, v := range ops {
+		if !absorbed(ops, i, q.Op) {
+			ret = ret.andOr(v, q.Op)
+		}
+	}
+	return ret.canonical()
+}
+
+// operands appends the simplified operands of q to s, flattening the nested operators op.
+func (q *Query) operands(s []*Query, op QueryOp) []*Query {
+	for _, v := range q.Literal {
+		s = append(s, LiteralQuery(v))
+	}
+	for _, v := range q.Sub {
+		v = v.simplify()
+		if len(v.Literal) == 0 && len(v.Sub) == 1 {
+			v = v.Sub[0]
+		}
+		if v.Op == op || (len(v.Literal) == 1 && len(v.Sub) == 0) {
+			s = v.operands(s, op)
+			continue
+		}
+		s = append(s, v)
+	}
+	return s
+}
+
+// absorbed reports whether the i-th operand is redundant for the operator op,
+// i.e. another operand implies it for AND, or it implies another operand for OR.
+// Of the operands equivalent to each other, the first one is kept.
+func absorbed(ops []*Query, i int, op QueryOp) bool {
+	for j := range ops {
+		if i == j {
+			continue
+		}
+		x, y := ops[i], ops[j]
+		if op == QOr {
+			x, y = y, x
+		}
+		if y.implies(x) && (!x.implies(y) || j < i) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonical returns the query whose sub queries are sorted by the string representations recursively.
+func (q *Query) canonical() *Query {
+	if len(q.Sub) == 0 {
+		return q
+	}
+	ret := q.clone()
+	keys := make([]string, len(ret.Sub))
+	for i, v := range ret.Sub {
+		ret.Sub[i] = v.canonical()
+		keys[i] = ret.Sub[i].String()
+	}
+	sort.Sort(queriesByKey{queries: ret.Sub, keys: keys})
+	return ret
+}
+
+type queriesByKey struct {
+	queries []*Query
+	keys    []string
+}
+
+func (s queriesByKey) Len() int           { return len(s.queries) }
+func (s queriesByKey) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s queriesByKey) Swap(i, j int) {
+	s.queries[i], s.queries[j] = s.queries[j], s.queries[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// KGrams returns the query which requires the k-grams (in bytes) of the literals instead of the literals,
+// e.g. for an n-gram index. A literal shorter than k has no k-gram to require, so it matches everything.
+func (q *Query) KGrams(k int) *Query {
+	if q.Op != QAnd && q.Op != QOr {
+		return q
+	}
+	ret := allQuery
+	if q.Op == QOr {
+		ret = noneQuery
+	}
+	for _, v := range q.Literal {
+		ret = ret.andOr(kgramQuery(v, k), q.Op)
+	}
+	for _, v := range q.Sub {
+		ret = ret.andOr(v.KGrams(k), q.Op)
+	}
+	return ret
+}
+
+// kgramQuery returns the query which requires all the k-grams of the literal.
+func kgramQuery(literal string, k int) *Query {
+	if k <= 0 || len(literal) < k {
+		return allQuery
+	}
+	grams := make([]string, 0, len(literal)-k+1)
+	for i := 0; i+k <= len(literal); i++ {
+		grams = append(grams, literal[i:i+k])
+	}
+	return &Query{Op: QAnd, Literal: cleanStrings(grams, false)}
+}
+
+// equal reports whether q and r are structurally identical.
+func (q *Query) equal(r *Query) bool {
+	if q == r {
+		return true
+	}
+	if q.Op != r.Op || len(q.Literal) != len(r.Literal) || len(q.Sub) != len(r.Sub) {
+		return false
+	}
+	for i := range q.Literal {
+		if q.Literal[i] != r.Literal[i] {
+			return false
+		}
+	}
+	for i := range q.Sub {
+		if !q.Sub[i].equal(r.Sub[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendQueries appends the queries to s except for the duplicates.
+func appendQueries(s []*Query, qs ...*Query) []*Query {
+loop:
+	for _, v := range qs {
+		for _, w := range s {
+			if v.equal(w) {
+				continue loop
+			}
+		}
+		s = append(s, v)
+	}
+	return s
+}
+
+func (q *Query) clone() *Query {
+	ret := *q
+	ret.Literal = append([]string(nil), q.Literal...)
+	ret.Sub = append([]*Query(nil), q.Sub...)
+	return &ret
+}
+
+// and returns q AND r.
+func (q *Query) and(r *Query) *Query {
+	return q.andOr(r, QAnd)
+}
+
+// or returns q OR r.
+func (q *Query) or(r *Query) *Query {
+	return q.andOr(r, QOr)
+}
+
+// andOr returns q op r with boolean simplification. It never modifies q and r.
+//
+//nolint:gocyclo
+func (q *Query) andOr(r *Query, op QueryOp) *Query {
+	if len(q.Literal) == 0 && len(q.Sub) == 1 {
+		q = q.Sub[0]
+	}
+	if len(r.Literal) == 0 && len(r.Sub) == 1 {
+		r = r.Sub[0]
+	}
+	// q AND q ≡ q OR q ≡ q.
+	if q.equal(r) {
+		return q
+	}
+	// If q ⇒ r, q AND r ≡ q and q OR r ≡ r.
+	if q.implies(r) {
+		if op == QAnd {
+			return q
+		}
+		return r
+	}
+	if r.implies(q) {
+		if op == QAnd {
+			return r
+		}
+		return q
+	}
+	q, r = q.clone(), r.clone()
+	// If they match or can be made to match, merge.
+	qAtom := len(q.Literal) == 1 && len(q.Sub) == 0
+	rAtom := len(r.Literal) == 1 && len(r.Sub) == 0
+	if q.Op == op && (r.Op == op || rAtom) {
+		q.Literal = unionStrings(q.Literal, r.Literal)
+		q.Sub = appendQueries(q.Sub, r.Sub...)
+		return q
+	}
+	if r.Op == op && qAtom {
+		r.Literal = unionStrings(r.Literal, q.Literal)
+		return r
+	}
+	if qAtom && rAtom {
+		q.Op = op
+		q.Literal = unionStrings(q.Literal, r.Literal)
+		return q
+	}
+	// If one matches the op, add the other to it.
+	if q.Op == op {
+		q.Sub = appendQueries(q.Sub, r)
+		return q
+	}
+	if r.Op == op {
+		r.Sub = appendQueries(r.Sub, q)
+		return r
+	}
+	// We are creating an AND of ORs or an OR of ANDs, factor out common literals, if any.
+	//	(abc|def|ghi) AND (abc|def|jkl) => (abc|def) OR ((ghi) AND (jkl))
+	//	(abc&def&ghi) OR (abc&def&jkl) => (abc&def) AND ((ghi) OR (jkl))
+	var common, qs, rs []string
+	i, j := 0, 0
+	for i < len(q.Literal) && j < len(r.Literal) {
+		switch qt, rt := q.Literal[i], r.Literal[j]; {
+		case qt < rt:
+			qs = append(qs, qt)
+			i++
+		case qt > rt:
+			rs = append(rs, rt)
+			j++
+		default:
+			common = append(common, qt)
+			i++
+			j++
+		}
+	}
+	qs = append(qs, q.Literal[i:]...)
+	rs = append(rs, r.Literal[j:]...)
+	if len(common) > 0 {
+		q.Literal, r.Literal = qs, rs
+		s := q.andOr(r, op)
+		t := &Query{Op: QAnd + QOr - op, Literal: common}
+		return t.andOr(s, t.Op)
+	}
+	return &Query{Op: op, Sub: []*Query{q, r}}
+}
+
+// implies reports whether q implies r. It is conservative and may return false negatives.
+func (q *Query) implies(r *Query) bool {
+	if q.Op == QNone || r.Op == QAll {
+		return true
+	}
+	if q.Op == QAll || r.Op == QNone {
+		return false
+	}
+	if q.Op == QAnd || (q.Op == QOr && len(q.Literal) == 1 && len(q.Sub) == 0) {
+		return literalsImply(q.Literal, r)
+	}
+	if q.Op == QOr && r.Op == QOr && len(q.Literal) > 0 && len(q.Sub) == 0 && isSubsetOfStrings(q.Literal, r.Literal) {
+		return true
+	}
+	return false
+}
+
+// literalsImply reports whether the AND of the literals implies q.
+func literalsImply(t []string, q *Query) bool {
+	switch q.Op {
+	case QOr:
+		for _, qq := range q.Sub {
+			if literalsImply(t, qq) {
+				return true
+			}
+		}
+		for i := range t {
+			if isSubsetOfStrings(t[i:i+1], q.Literal) {
+				return true
+			}
+		}
+		return false
+	case QAnd:
+		for _, qq := range q.Sub {
+			if !literalsImply(t, qq) {
+				return false
+			}
+		}
+		return isSubsetOfStrings(q.Literal, t)
+	}
+	return false
+}
+
+// unionStrings returns the sorted union of sorted string slices.
+func unionStrings(x, y []string) []string {
+	ret := make([]string, 0, len(x)+len(y))
+	ret = append(ret, x...)
+	ret = append(ret, y...)
+	return cleanStrings(ret, false)
+}
+
+// isSubsetOfStrings reports whether every string of sorted x is in sorted y.
+func isSubsetOfStrings(x, y []string) bool {
+	for len(x) > 0 && len(y) > 0 {
+		switch {
+		case x[0] == y[0]:
+			x = x[1:]
+			y = y[1:]
+		case x[0] > y[0]:
+			y = y[1:]
+		default:
+			return false
+		}
+	}
+	return len(x) == 0
+}
+
+// cleanStrings sorts (by reversed strings if isSuffix) and removes duplicates in place.
+func cleanStrings(s []string, isSuffix bool) []string {
+	if isSuffix {
+		sortByRevertedString(s)
+	} else {
+		sort.Strings(s)
+	}
+	w := 0
+	for _, v := range s {
+		if w == 0 || s[w-1] != v {
+			s[w] = v
+			w++
+		}
+	}
+	return s[:w]
+}
@@ -0,0 +1,197 @@
+package factors
+
+import (
+	"math/rand"
+	"regexp/syntax"
+	"strings"
+	"testing"
+)
+
+func TestQuery_Simplify(t *testing.T) {
+	lit := LiteralQuery
+	tests := []struct {
+		name string
+		q    *Query
+		want string
+	}{
+		{name: "all", q: AllQuery(), want: `+`},
+		{name: "empty and", q: &Query{Op: QAnd}, want: `+`},
+		{name: "empty or", q: &Query{Op: QOr}, want: `-`},
+		{name: "duplicates", q: &Query{Op: QAnd, Literal: []string{"b", "a", "b"}}, want: `"a" "b"`},
+		{name: "flatten", q: &Query{Op: QAnd, Literal: []string{"a"}, Sub: []*Query{{Op: QAnd, Literal: []string{"b", "c"}}}}, want: `"a" "b" "c"`},
+		{name: "absorption", q: &Query{Op: QAnd, Literal: []string{"a"}, Sub: []*Query{{Op: QOr, Literal: []string{"a", "b"}}}}, want: `"a"`},
+		{name: "identity", q: &Query{Op: QOr, Sub: []*Query{NoneQuery(), lit("a")}}, want: `"a"`},
+		{name: "annihilator", q: &Query{Op: QAnd, Sub: []*Query{NoneQuery(), lit("a")}}, want: `-`},
+		{name: "common literals", q: &Query{Op: QOr, Sub: []*Query{{Op: QAnd, Literal: []string{"a", "b"}}, {Op: QAnd, Literal: []string{"a", "c"}}}}, want: `"a" ("b"|"c")`},
+		{
+			name: "duplicate sub queries",
+			q: &Query{Op: QAnd, Sub: []*Query{
+				{Op: QOr, Literal: []string{"a", "b"}},
+				{Op: QOr, Literal: []string{"c", "d"}},
+				{Op: QOr, Literal: []string{"a", "b"}},
+			}},
+			want: `("a"|"b") ("c"|"d")`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.q.Simplify().String(); got != tt.want {
+				t.Errorf("Simplify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuery_Simplify_Canonical(t *testing.T) {
+	x := &Query{Op: QOr, Literal: []string{"a", "b"}}
+	y := &Query{Op: QOr, Literal: []string{"c", "d"}}
+	z := &Query{Op: QOr, Sub: []*Query{{Op: QAnd, Literal: []string{"e", "f"}}, {Op: QAnd, Literal: []string{"g", "h"}}}}
+	want := x.And(y).And(z).Simplify().String()
+	for _, q := range []*Query{
+		z.And(y).And(x),
+		y.And(x.And(z)),
+		{Op: QAnd, Sub: []*Query{z, x, y, x}},
+	} {
+		if got := q.Simplify().String(); got != want {
+			t.Errorf("Simplify() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestQuery_Simplify_Permutation(t *testing.T) {
+	lit := LiteralQuery
+	or := func(s ...string) *Query { return &Query{Op: QOr, Literal: s} }
+	for _, tt := range []struct {
+		q, p *Query
+		want string
+	}{
+		{
+			q:    &Query{Op: QAnd, Literal: []string{"c"}, Sub: []*Query{lit("d"), or("d", "b")}},
+			p:    &Query{Op: QAnd, Literal: []string{"c"}, Sub: []*Query{or("d", "b"), lit("d")}},
+			want: `"c" "d"`,
+		},
+		{
+			q:    &Query{Op: QAnd, Sub: []*Query{or("b", "d"), lit("c"), lit("d")}},
+			p:    &Query{Op: QAnd, Sub: []*Query{lit("d"), lit("c"), or("b", "d")}},
+			want: `"c" "d"`,
+		},
+	} {
+		if got := tt.q.Simplify().String(); got != tt.want {
+			t.Errorf("Simplify(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+		if got := tt.p.Simplify().String(); got != tt.want {
+			t.Errorf("Simplify(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+
+	r := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "c", "d", "e"}
+	var random func(depth int) *Query
+	random = func(depth int) *Query {
+		q := &Query{Op: QAnd + QueryOp(r.Intn(2))}
+		for i := r.Intn(3); i > 0; i-- {
+			q.Literal = append(q.Literal, alphabet[r.Intn(len(alphabet))])
+		}
+		for i := r.Intn(3); depth > 0 && i > 0; i-- {
+			q.Sub = append(q.Sub, random(depth-1))
+		}
+		return q
+	}
+	var permute func(q *Query) *Query
+	permute = func(q *Query) *Query {
+		ret := q.clone()
+		r.Shuffle(len(ret.Literal), func(i, j int) { ret.Literal[i], ret.Literal[j] = ret.Literal[j], ret.Literal[i] })
+		r.Shuffle(len(ret.Sub), func(i, j int) { ret.Sub[i], ret.Sub[j] = ret.Sub[j], ret.Sub[i] })
+		for i, v := range ret.Sub {
+			ret.Sub[i] = permute(v)
+		}
+		return ret
+	}
+	for i := 0; i < 1000; i++ {
+		q := random(3)
+		s := q.Simplify()
+		if got, want := permute(q).Simplify().String(), s.String(); got != want {
+			t.Fatalf("Simplify(%v) = %v, but Simplify of its permutation = %v", q, want, got)
+		}
+		if got, want := s.Simplify().String(), s.String(); got != want {
+			t.Fatalf("Simplify(%v) = %v, but Simplify of it = %v", q, want, got)
+		}
+		for bits := 0; bits < 1<<len(alphabet); bits++ {
+			has := func(literal string) bool { return bits&(1<<(literal[0]-'a')) != 0 }
+			if q.Eval(has) != s.Eval(has) {
+				t.Fatalf("Simplify(%v) = %v, not equivalent for %05b", q, s, bits)
+			}
+		}
+	}
+}
+
+func TestQuery_KGrams(t *testing.T) {
+	tests := []struct {
+		name string
+		q    *Query
+		k    int
+		want string
+	}{
+		{name: "literal", q: LiteralQuery("abcd"), k: 3, want: `"abc" "bcd"`},
+		{name: "short literal", q: LiteralQuery("ab"), k: 3, want: `+`},
+		{name: "and", q: &Query{Op: QAnd, Literal: []string{"ab", "abcd"}}, k: 2, want: `"ab" "bc" "cd"`},
+		{name: "or with a short literal", q: &Query{Op: QOr, Literal: []string{"ab", "abcd"}}, k: 3, want: `+`},
+		{name: "or", q: &Query{Op: QOr, Literal: []string{"abcd", "abce"}}, k: 3, want: `"abc" ("bcd"|"bce")`},
+		{name: "none", q: NoneQuery(), k: 3, want: `-`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.q.KGrams(tt.k).String(); got != tt.want {
+				t.Errorf("KGrams(%d) = %v, want %v", tt.k, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFactor_Query(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: `abc`, want: `"abc"`},
+		{pattern: `abc.*(def|ghi)`, want: `"abc" ("def"|"ghi")`},
+		{pattern: `a*`, want: `+`},
+		{pattern: `^$`, want: `+`},
+		{pattern: `^$|x`, want: `+`},
+		{pattern: `(?:$){2}|x`, want: `+`},
+		{pattern: `\bfoo\b|^$`, want: `+`},
+	}
+	for _, tt := range tests {
+		re, err := syntax.Parse(tt.pattern, syntax.Perl)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		if got := NewAnalyzer().Factor(re).Query().String(); got != tt.want {
+			t.Errorf("Factor(%q).Query() = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+	// No text contains an item of an empty set.
+	f := NewFactorInfinite()
+	f.Fragment = NewSet()
+	if got, want := f.Query().String(), `-`; got != want {
+		t.Errorf("Query() of an empty fragment set = %v, want %v", got, want)
+	}
+}
+
+func TestQuery_Eval(t *testing.T) {
+	q := &Query{Op: QAnd, Literal: []string{"abc"}, Sub: []*Query{{Op: QOr, Literal: []string{"def", "ghi"}}}}
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{text: "abcdef", want: true},
+		{text: "ghi abc", want: true},
+		{text: "abc", want: false},
+		{text: "defghi", want: false},
+	}
+	for _, tt := range tests {
+		if got := q.Eval(func(literal string) bool { return strings.Contains(tt.text, literal) }); got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,503 @@
+package factors
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+)
+
+// Regexp is a compiled regular expression with the API of regexp.Regexp.
+// It rejects texts which never match by the prefilter of the necessary factors before running the regexp,
+// and the Match and Find methods run the regexp only in the windows around the occurrences of the fragments
+// if the matches are bounded, as WindowMatcher does. The results are identical to the ones of regexp.Regexp.
+// The methods which are not defined here, e.g. MatchReader, are the ones of regexp.Regexp.
+type Regexp struct {
+	*regexp.Regexp
+	// prefilter is nil if the regexp runs on every text,
+	// e.g. an alternative may match the empty string, which the factors hardly describe.
+	prefilter *Prefilter
+	// window finds the windows of the matches, nil if the regexp runs on the whole text.
+	window *WindowMatcher
+}
+
+// Compile parses a regular expression as regexp.Compile does, and returns a Regexp.
+func Compile(expr string) (*Regexp, error) {
+	return compile(expr, syntax.Perl, false)
+}
+
+// CompilePOSIX parses a regular expression as regexp.CompilePOSIX does, and returns a Regexp.
+func CompilePOSIX(expr string) (*Regexp, error) {
+	return compile(expr, syntax.POSIX, true)
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed.
+func MustCompile(str string) *Regexp {
+	re, err := Compile(str)
+	if err != nil {
+		panic(`factors: Compile(` + quote(str) + `): ` + err.Error())
+	}
+	return re
+}
+
+// MustCompilePOSIX is like CompilePOSIX but panics if the expression cannot be parsed.
+func MustCompilePOSIX(str string) *Regexp {
+	re, err := CompilePOSIX(str)
+	if err != nil {
+		panic(`factors: CompilePOSIX(` + quote(str) + `): ` + err.Error())
+	}
+	return re
+}
+
+func compile(expr string, flags syntax.Flags, longest bool) (*Regexp, error) {
+	var re *regexp.Regexp
+	var err error
+	if longest {
+		re, err = regexp.CompilePOSIX(expr)
+	} else {
+		re, err = regexp.Compile(expr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sre, err := syntax.Parse(expr, flags)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Regexp{Regexp: re}
+	if !hasEmptyAlternative(sre) {
+		a := NewAnalyzer()
+		ret.prefilter = a.Prefilter(sre)
+		if w := a.windowMatcher(re, sre); w.ac != nil {
+			ret.window = w
+		}
+	}
+	return ret, nil
+}
+
+// hasEmptyAlternative returns true if an alternative of the regexp may match the empty string, e.g. `^$|x`.
+func hasEmptyAlternative(re *syntax.Regexp) bool {
+	if re.Op == syntax.OpAlternate {
+		for _, v := range re.Sub {
+			if lo, _ := lengthRange(v); lo == 0 {
+				return true
+			}
+		}
+	}
+	for _, v := range re.Sub {
+		if hasEmptyAlternative(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchString reports whether the string s contains any match of the regular expression pattern.
+// It is regexp.MatchString, the analysis of the pattern costs more than it saves for a single text.
+func MatchString(pattern string, s string) (matched bool, err error) {
+	return regexp.MatchString(pattern, s)
+}
+
+// Match reports whether the byte slice b contains any match of the regular expression pattern.
+// It is regexp.Match, the analysis of the pattern costs more than it saves for a single text.
+func Match(pattern string, b []byte) (matched bool, err error) {
+	return regexp.Match(pattern, b)
+}
+
+// QuoteMeta is regexp.QuoteMeta.
+func QuoteMeta(s string) string {
+	return regexp.QuoteMeta(s)
+}
+
+// Copy returns a new Regexp copied from re.
+//
+// Deprecated: In earlier releases of regexp, Copy was for using a Regexp in multiple goroutines.
+// A Regexp is safe for concurrent use.
+func (re *Regexp) Copy() *Regexp {
+	ret := *re
+	ret.Regexp = re.Regexp.Copy() //nolint:staticcheck
+	return &ret
+}
+
+// Prefilter returns the prefilter of the regexp, nil if the regexp runs on every text.
+func (re *Regexp) Prefilter() *Prefilter {
+	return re.prefilter
+}
+
+func (re *Regexp) mayMatch(b []byte) bool {
+	return re.prefilter == nil || re.prefilter.MayMatch(b)
+}
+
+func (re *Regexp) mayMatchString(s string) bool {
+	return re.prefilter == nil || re.prefilter.MayMatchString(s)
+}
+
+// Match is regexp.Regexp.Match.
+func (re *Regexp) Match(b []byte) bool {
+	if re.window != nil {
+		return re.mayMatch(b) && re.window.Match(b)
+	}
+	return re.mayMatch(b) && re.Regexp.Match(b)
+}
+
+// MatchString is regexp.Regexp.MatchString.
+func (re *Regexp) MatchString(s string) bool {
+	if re.window != nil {
+		return re.mayMatchString(s) && re.window.Match([]byte(s))
+	}
+	return re.mayMatchString(s) && re.Regexp.MatchString(s)
+}
+
+// Find is regexp.Regexp.Find.
+func (re *Regexp) Find(b []byte) []byte {
+	if !re.mayMatch(b) {
+		return nil
+	}
+	if re.window != nil {
+		if loc := re.findIndex(b, false); loc != nil {
+			return b[loc[0]:loc[1]:loc[1]]
+		}
+		return nil
+	}
+	return re.Regexp.Find(b)
+}
+
+// FindIndex is regexp.Regexp.FindIndex.
+func (re *Regexp) FindIndex(b []byte) []int {
+	if !re.mayMatch(b) {
+		return nil
+	}
+	if re.window != nil {
+		return re.findIndex(b, false)
+	}
+	return re.Regexp.FindIndex(b)
+}
+
+// FindString is regexp.Regexp.FindString.
+func (re *Regexp) FindString(s string) string {
+	if !re.mayMatchString(s) {
+		return ""
+	}
+	if re.window != nil {
+		if loc := re.findStringIndex(s, false); loc != nil {
+			return s[loc[0]:loc[1]]
+		}
+		return ""
+	}
+	return re.Regexp.FindString(s)
+}
+
+// FindStringIndex is regexp.Regexp.FindStringIndex.
+func (re *Regexp) FindStringIndex(s string) []int {
+	if !re.mayMatchString(s) {
+		return nil
+	}
+	if re.window != nil {
+		return re.findStringIndex(s, false)
+	}
+	return re.Regexp.FindStringIndex(s)
+}
+
+// FindSubmatch is regexp.Regexp.FindSubmatch.
+func (re *Regexp) FindSubmatch(b []byte) [][]byte {
+	if !re.mayMatch(b) {
+		return nil
+	}
+	if re.window != nil {
+		if loc := re.findIndex(b, true); loc != nil {
+			return submatches(b, loc)
+		}
+		return nil
+	}
+	return re.Regexp.FindSubmatch(b)
+}
+
+// FindSubmatchIndex is regexp.Regexp.FindSubmatchIndex.
+func (re *Regexp) FindSubmatchIndex(b []byte) []int {
+	if !re.mayMatch(b) {
+		return nil
+	}
+	if re.window != nil {
+		return re.findIndex(b, true)
+	}
+	return re.Regexp.FindSubmatchIndex(b)
+}
+
+// FindStringSubmatch is regexp.Regexp.FindStringSubmatch.
+func (re *Regexp) FindStringSubmatch(s string) []string {
+	if !re.mayMatchString(s) {
+		return nil
+	}
+	if re.window != nil {
+		if loc := re.findStringIndex(s, true); loc != nil {
+			return stringSubmatches(s, loc)
+		}
+		return nil
+	}
+	return re.Regexp.FindStringSubmatch(s)
+}
+
+// FindStringSubmatchIndex is regexp.Regexp.FindStringSubmatchIndex.
+func (re *Regexp) FindStringSubmatchIndex(s string) []int {
+	if !re.mayMatchString(s) {
+		return nil
+	}
+	if re.window != nil {
+		return re.findStringIndex(s, true)
+	}
+	return re.Regexp.FindStringSubmatchIndex(s)
+}
+
+// FindAll is regexp.Regexp.FindAll.
+func (re *Regexp) FindAll(b []byte, n int) [][]byte {
+	if !re.mayMatch(b) {
+		return nil
+	}
+	if re.window != nil {
+		var ret [][]byte
+		for _, loc := range re.findAllIndex(b, n, false) {
+			ret = append(ret, b[loc[0]:loc[1]:loc[1]])
+		}
+		return ret
+	}
+	return re.Regexp.FindAll(b, n)
+}
+
+// FindAllIndex is regexp.Regexp.FindAllIndex.
+func (re *Regexp) FindAllIndex(b []byte, n int) [][]int {
+	if !re.mayMatch(b) {
+		return nil
+	}
+	if re.window != nil {
+		return re.findAllIndex(b, n, false)
+	}
+	return re.Regexp.FindAllIndex(b, n)
+}
+
+// FindAllString is regexp.Regexp.FindAllString.
+func (re *Regexp) FindAllString(s string, n int) []string {
+	if !re.mayMatchString(s) {
+		return nil
+	}
+	if re.window != nil {
+		var ret []string
+		for _, loc := range re.findAllStringIndex(s, n, false) {
+			ret = append(ret, s[loc[0]:loc[1]])
+		}
+		return ret
+	}
+	return re.Regexp.FindAllString(s, n)
+}
+
+// FindAllStringIndex is regexp.Regexp.FindAllStringIndex.
+func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
+	if !re.mayMatchString(s) {
+		return nil
+	}
+	if re.window != nil {
+		return re.findAllStringIndex(s, n, false)
+	}
+	return re.Regexp.FindAllStringIndex(s, n)
+}
+
+// FindAllSubmatch is regexp.Regexp.FindAllSubmatch.
+func (re *Regexp) FindAllSubmatch(b []byte, n int) [][][]byte {
+	if !re.mayMatch(b) {
+		return nil
+	}
+	if re.window != nil {
+		var ret [][][]byte
+		for _, loc := range re.findAllIndex(b, n, true) {
+			ret = append(ret, submatches(b, loc))
+		}
+		return ret
+	}
+	return re.Regexp.FindAllSubmatch(b, n)
+}
+
+// FindAllSubmatchIndex is regexp.Regexp.FindAllSubmatchIndex.
+func (re *Regexp) FindAllSubmatchIndex(b []byte, n int) [][]int {
+	if !re.mayMatch(b) {
+		return nil
+	}
+	if re.window != nil {
+		return re.findAllIndex(b, n, true)
+	}
+	return re.Regexp.FindAllSubmatchIndex(b, n)
+}
+
+// FindAllStringSubmatch is regexp.Regexp.FindAllStringSubmatch.
+func (re *Regexp) FindAllStringSubmatch(s string, n int) [][]string {
+	if !re.mayMatchString(s) {
+		return nil
+	}
+	if re.window != nil {
+		var ret [][]string
+		for _, loc := range re.findAllStringIndex(s, n, true) {
+			ret = append(ret, stringSubmatches(s, loc))
+		}
+		return ret
+	}
+	return re.Regexp.FindAllStringSubmatch(s, n)
+}
+
+// FindAllStringSubmatchIndex is regexp.Regexp.FindAllStringSubmatchIndex.
+func (re *Regexp) FindAllStringSubmatchIndex(s string, n int) [][]int {
+	if !re.mayMatchString(s) {
+		return nil
+	}
+	if re.window != nil {
+		return re.findAllStringIndex(s, n, true)
+	}
+	return re.Regexp.FindAllStringSubmatchIndex(s, n)
+}
+
+// findIndex returns the indexes of the leftmost match in the windows, with the ones of the submatches if submatch.
+func (re *Regexp) findIndex(b []byte, submatch bool) []int {
+	if ret := re.findAllIndex(b, 1, submatch); ret != nil {
+		return ret[0]
+	}
+	return nil
+}
+
+// findStringIndex is findIndex for a string.
+func (re *Regexp) findStringIndex(s string, submatch bool) []int {
+	if ret := re.findAllStringIndex(s, 1, submatch); ret != nil {
+		return ret[0]
+	}
+	return nil
+}
+
+// findAllIndex returns the indexes of successive matches in the windows, with the ones of the submatches if submatch.
+func (re *Regexp) findAllIndex(b []byte, n int, submatch bool) [][]int {
+	return re.allIndex(b, n, func(lo, hi, n int) [][]int {
+		if submatch {
+			return re.Regexp.FindAllSubmatchIndex(b[lo:hi], n)
+		}
+		return re.Regexp.FindAllIndex(b[lo:hi], n)
+	})
+}
+
+// findAllStringIndex is findAllIndex for a string. The windows are found in a copy of the string.
+func (re *Regexp) findAllStringIndex(s string, n int, submatch bool) [][]int {
+	return re.allIndex([]byte(s), n, func(lo, hi, n int) [][]int {
+		if submatch {
+			return re.Regexp.FindAllStringSubmatchIndex(s[lo:hi], n)
+		}
+		return re.Regexp.FindAllStringIndex(s[lo:hi], n)
+	})
+}
+
+// allIndex returns at most n indexes which find returns for the windows of the text in order,
+// shifted to the positions in the text. Every match is in a window and is not empty,
+// so they are the ones of successive matches in the text.
+func (re *Regexp) allIndex(b []byte, n int, find func(lo, hi, n int) [][]int) [][]int {
+	if n == 0 {
+		return nil
+	}
+	var ret [][]int
+	re.window.windows(b, func(w Span) bool {
+		limit := -1
+		if n > 0 {
+			limit = n - len(ret)
+		}
+		for _, loc := range find(w.Start, w.End, limit) {
+			for i := range loc {
+				if loc[i] >= 0 {
+					loc[i] += w.Start
+				}
+			}
+			ret = append(ret, loc)
+		}
+		return n < 0 || len(ret) < n
+	})
+	return ret
+}
+
+// submatches returns the slices of the text for the submatch indexes, nil for the unmatched groups.
+func submatches(b []byte, loc []int) [][]byte {
+	ret := make([][]byte, len(loc)/2)
+	for i := range ret {
+		if loc[2*i] >= 0 {
+			ret[i] = b[loc[2*i]:loc[2*i+1]:loc[2*i+1]]
+		}
+	}
+	return ret
+}
+
+// stringSubmatches returns the substrings of the text for the submatch indexes, "" for the unmatched groups.
+func stringSubmatches(s string, loc []int) []string {
+	ret := make([]string, len(loc)/2)
+	for i := range ret {
+		if loc[2*i] >= 0 {
+			ret[i] = s[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return ret
+}
+
+// ReplaceAll is regexp.Regexp.ReplaceAll.
+func (re *Regexp) ReplaceAll(src, repl []byte) []byte {
+	if !re.mayMatch(src) {
+		return append([]byte(nil), src...)
+	}
+	return re.Regexp.ReplaceAll(src, repl)
+}
+
+// ReplaceAllLiteral is regexp.Regexp.ReplaceAllLiteral.
+func (re *Regexp) ReplaceAllLiteral(src, repl []byte) []byte {
+	if !re.mayMatch(src) {
+		return append([]byte(nil), src...)
+	}
+	return re.Regexp.ReplaceAllLiteral(src, repl)
+}
+
+// ReplaceAllFunc is regexp.Regexp.ReplaceAllFunc.
+func (re *Regexp) ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte {
+	if !re.mayMatch(src) {
+		return append([]byte(nil), src...)
+	}
+	return re.Regexp.ReplaceAllFunc(src, repl)
+}
+
+// ReplaceAllString is regexp.Regexp.ReplaceAllString.
+func (re *Regexp) ReplaceAllString(src, repl string) string {
+	if !re.mayMatchString(src) {
+		return src
+	}
+	return re.Regexp.ReplaceAllString(src, repl)
+}
+
+// ReplaceAllLiteralString is regexp.Regexp.ReplaceAllLiteralString.
+func (re *Regexp) ReplaceAllLiteralString(src, repl string) string {
+	if !re.mayMatchString(src) {
+		return src
+	}
+	return re.Regexp.ReplaceAllLiteralString(src, repl)
+}
+
+// ReplaceAllStringFunc is regexp.Regexp.ReplaceAllStringFunc.
+func (re *Regexp) ReplaceAllStringFunc(src string, repl func(string) string) string {
+	if !re.mayMatchString(src) {
+		return src
+	}
+	return re.Regexp.ReplaceAllStringFunc(src, repl)
+}
+
+// Split is regexp.Regexp.Split.
+func (re *Regexp) Split(s string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+	if !re.mayMatchString(s) {
+		return []string{s}
+	}
+	return re.Regexp.Split(s, n)
+}
+
+// quote returns a quoted pattern as the regexp package quotes it in a panic message.
+func quote(s string) string {
+	if strconv.CanBackquote(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,218 @@
+package factors
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var regexpPatterns = []string{
+	`abc`,
+	`a(b|c)d`,
+	`(?i)hello`,
+	`^ab`,
+	`b+$`,
+	`^(ab|cd)$`,
+	`(\w+)@(\w+)\.com`,
+	`a*`,
+	``,
+	`x|`,
+	`\bab\b`,
+	`(?m)^b.*`,
+	`[^a]b`,
+	`\x{FFFD}`,
+	`a.c`,
+	`(?s)a.+?c`,
+	`(a)(b)?(c)`,
+	`世界`,
+	`^$|x`,
+	`(?:$){2}|x`,
+	`(?:^|é)(?:^|é)`,
+	`\bfoo\b|^$`,
+	`(?:^|,)x`,
+	`(a|b)c.{0,3}d`,
+	`(?i)k(x)?y`,
+}
+
+func regexpTexts() []string {
+	ret := []string{"", "abc", "abd", "HeLLo world", "ab", "xab\ncd", "bbb", "cd", "foo@bar.com", "\xff", "a\xffc", "世界", "ab ab", "acabc", "x", "é", "a", "foo", "a foo", "ax,x"}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		b := make([]byte, r.Intn(10))
+		for j := range b {
+			b[j] = "abcd @.\n\xff"[r.Intn(9)]
+		}
+		ret = append(ret, string(b))
+	}
+	return ret
+}
+
+func TestRegexp(t *testing.T) {
+	texts := regexpTexts()
+	for _, pattern := range regexpPatterns {
+		for _, posix := range []bool{false, true} {
+			want, err := regexp.Compile(pattern)
+			compile := Compile
+			if posix {
+				want, err = regexp.CompilePOSIX(pattern)
+				compile = CompilePOSIX
+			}
+			got, gotErr := compile(pattern)
+			if (err != nil) != (gotErr != nil) {
+				t.Fatalf("%s: error = %v, want %v", pattern, gotErr, err)
+			}
+			if err != nil {
+				continue
+			}
+			for _, s := range texts {
+				testRegexpEqual(t, pattern, s, got, want)
+			}
+		}
+	}
+}
+
+func testRegexpEqual(t *testing.T, pattern, s string, got *Regexp, want *regexp.Regexp) {
+	t.Helper()
+	b := []byte(s)
+	upper := func(s string) string { return strings.ToUpper(s) }
+	upperBytes := func(b []byte) []byte { return bytes.ToUpper(b) }
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"Match", got.Match(b), want.Match(b)},
+		{"MatchString", got.MatchString(s), want.MatchString(s)},
+		{"Find", got.Find(b), want.Find(b)},
+		{"FindIndex", got.FindIndex(b), want.FindIndex(b)},
+		{"FindString", got.FindString(s), want.FindString(s)},
+		{"FindStringIndex", got.FindStringIndex(s), want.FindStringIndex(s)},
+		{"FindSubmatch", got.FindSubmatch(b), want.FindSubmatch(b)},
+		{"FindSubmatchIndex", got.FindSubmatchIndex(b), want.FindSubmatchIndex(b)},
+		{"FindStringSubmatch", got.FindStringSubmatch(s), want.FindStringSubmatch(s)},
+		{"FindStringSubmatchIndex", got.FindStringSubmatchIndex(s), want.FindStringSubmatchIndex(s)},
+		{"FindAll", got.FindAll(b, -1), want.FindAll(b, -1)},
+		{"FindAllIndex", got.FindAllIndex(b, 2), want.FindAllIndex(b, 2)},
+		{"FindAllString", got.FindAllString(s, -1), want.FindAllString(s, -1)},
+		{"FindAllStringIndex", got.FindAllStringIndex(s, -1), want.FindAllStringIndex(s, -1)},
+		{"FindAllSubmatch", got.FindAllSubmatch(b, -1), want.FindAllSubmatch(b, -1)},
+		{"FindAllSubmatchIndex", got.FindAllSubmatchIndex(b, -1), want.FindAllSubmatchIndex(b, -1)},
+		{"FindAllStringSubmatch", got.FindAllStringSubmatch(s, -1), want.FindAllStringSubmatch(s, -1)},
+		{"FindAllStringSubmatchIndex", got.FindAllStringSubmatchIndex(s, 1), want.FindAllStringSubmatchIndex(s, 1)},
+		{"ReplaceAll", got.ReplaceAll(b, []byte("<$0>")), want.ReplaceAll(b, []byte("<$0>"))},
+		{"ReplaceAllLiteral", got.ReplaceAllLiteral(b, []byte("$0")), want.ReplaceAllLiteral(b, []byte("$0"))},
+		{"ReplaceAllFunc", got.ReplaceAllFunc(b, upperBytes), want.ReplaceAllFunc(b, upperBytes)},
+		{"ReplaceAllString", got.ReplaceAllString(s, "<$0>"), want.ReplaceAllString(s, "<$0>")},
+		{"ReplaceAllLiteralString", got.ReplaceAllLiteralString(s, "$0"), want.ReplaceAllLiteralString(s, "$0")},
+		{"ReplaceAllStringFunc", got.ReplaceAllStringFunc(s, upper), want.ReplaceAllStringFunc(s, upper)},
+		{"Split", got.Split(s, -1), want.Split(s, -1)},
+		{"Split 0", got.Split(s, 0), want.Split(s, 0)},
+		{"Split 2", got.Split(s, 2), want.Split(s, 2)},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: %s(%q) = %#v, want %#v", pattern, tt.name, s, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRegexp_Prefilter(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: `abc`, want: `index "abc"`},
+		{pattern: `^ab`, want: `prefix {ab} AND index "ab"`},
+		{pattern: `^$|x`, want: ``},
+		{pattern: `\bfoo\b|^$`, want: ``},
+	}
+	for _, tt := range tests {
+		var got string
+		if p := MustCompile(tt.pattern).Prefilter(); p != nil {
+			got = p.String()
+		}
+		if got != tt.want {
+			t.Errorf("%s: Prefilter() = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestRegexp_window(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{pattern: `a(b|c)d`, want: true},
+		{pattern: `(a|b)c.{0,3}d`, want: true},
+		{pattern: `(\w+)@(\w+)\.com`, want: false},
+		{pattern: `\bab\b`, want: false},
+		{pattern: `^$|x`, want: false},
+	}
+	for _, tt := range tests {
+		if got := MustCompile(tt.pattern).window != nil; got != tt.want {
+			t.Errorf("%s: window = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestMustCompile(t *testing.T) {
+	re := MustCompile(`a+b`)
+	if got, want := re.String(), `a+b`; got != want {
+		t.Errorf("String() = %v, want %v", got, want)
+	}
+	if got, want := re.Copy().FindString("xaab"), "aab"; got != want {
+		t.Errorf("Copy().FindString() = %v, want %v", got, want)
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	MustCompile(`a(`)
+}
+
+func TestMatchString(t *testing.T) {
+	if ok, err := MatchString(`a+b`, "xaab"); err != nil || !ok {
+		t.Errorf("MatchString() = %v, %v, want true", ok, err)
+	}
+	if ok, err := Match(`a+b`, []byte("xa")); err != nil || ok {
+		t.Errorf("Match() = %v, %v, want false", ok, err)
+	}
+	if _, err := MatchString(`a(`, ""); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func BenchmarkRegexp_MatchString(b *testing.B) {
+	text := strings.Repeat("the quick brown fox jumps over the lazy dog. ", 100)
+	b.Run("regexp", func(b *testing.B) {
+		re := regexp.MustCompile(`(error|warning): \w+ not found`)
+		for i := 0; i < b.N; i++ {
+			re.MatchString(text)
+		}
+	})
+	b.Run("factors", func(b *testing.B) {
+		re := MustCompile(`(error|warning): \w+ not found`)
+		for i := 0; i < b.N; i++ {
+			re.MatchString(text)
+		}
+	})
+}
+
+func BenchmarkRegexp_FindAllIndex(b *testing.B) {
+	text := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 100) + "error: file not found")
+	b.Run("regexp", func(b *testing.B) {
+		re := regexp.MustCompile(`(error|warning): .{0,10} not found`)
+		for i := 0; i < b.N; i++ {
+			re.FindAllIndex(text, -1)
+		}
+	})
+	b.Run("factors", func(b *testing.B) {
+		re := MustCompile(`(error|warning): .{0,10} not found`)
+		for i := 0; i < b.N; i++ {
+			re.FindAllIndex(text, -1)
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package factors
+
+import (
+	"bytes"
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+
+	"github.com/ikawaha/factors/factors/ahocorasick"
+)
+
+// reverseScanBudget is the number of the bytes per byte of the text which the reverse scans may pass over.
+// The scans from the occurrences may pass over the same bytes again and again, e.g. `a[a-z]*x` for "bxx…x",
+// so FindAllIndex searches the rest of the text with the regexp once they exceed it.
+const reverseScanBudget = 4
+
+// ReverseSuffixMatcher searches a regexp from the occurrences of the suffixes of the matches,
+// which is efficient for a regexp whose selective literal is at the end, e.g. `[a-z0-9._]+@example\.com`.
+// A match ends with an item of the suffix set, so it finds the items, runs the reversed regexp backward
+// from the end of each occurrence to locate the start of the match, and confirms the match forward from the start.
+// The results are identical to the ones of regexp.Regexp.
+type ReverseSuffixMatcher struct {
+	re *regexp.Regexp
+	// forward is the regexp anchored at the beginning, and reverse is the reversed regexp anchored at the beginning
+	// with the leftmost-longest semantics. They are nil if it searches with re, e.g. the suffix set is θ
+	// or the regexp has empty width assertions, which depend on the text out of the matches.
+	forward *regexp.Regexp
+	reverse *regexp.Regexp
+	// maxLen is the maximum length of the matches, -1 if unbounded. The reverse scans do not pass over it.
+	maxLen int
+	items  []string
+	ac     *ahocorasick.Automaton
+}
+
+// NewReverseSuffixMatcher parses (with syntax.Perl flags) a given pattern and returns a reverse suffix matcher of it.
+func NewReverseSuffixMatcher(pattern string) (*ReverseSuffixMatcher, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sre, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	ret := &ReverseSuffixMatcher{re: re}
+	f := NewAnalyzer().Factor(sre)
+	if f.Suffix.infinite || f.Suffix.size() == 0 || f.Suffix.Contains("") || hasEmptyWidth(sre) {
+		return ret, nil
+	}
+	if ret.forward, err = regexp.Compile(`^(?:` + pattern + `)`); err != nil {
+		return nil, err
+	}
+	if ret.reverse, err = regexp.Compile(`^(?:` + reverseRegexp(sre).String() + `)`); err != nil {
+		return nil, err
+	}
+	ret.reverse.Longest()
+	_, ret.maxLen = lengthRange(sre)
+	ret.items = f.Suffix.Items()
+	ret.ac = ahocorasick.New(ret.items)
+	return ret, nil
+}
+
+// reverseRegexp returns the regexp which matches the reversed strings of the matches of a given regexp
+// without empty width assertions.
+func reverseRegexp(re *syntax.Regexp) *syntax.Regexp {
+	ret := *re
+	switch re.Op {
+	case syntax.OpLiteral:
+		ret.Rune = make([]rune, len(re.Rune))
+		for i, r := range re.Rune {
+			ret.Rune[len(re.Rune)-1-i] = r
+		}
+		return &ret
+	case syntax.OpCapture:
+		return reverseRegexp(re.Sub[0])
+	}
+	if len(re.Sub) == 0 {
+		return &ret
+	}
+	ret.Sub = make([]*syntax.Regexp, len(re.Sub))
+	for i, v := range re.Sub {
+		ret.Sub[i] = reverseRegexp(v)
+	}
+	if re.Op == syntax.OpConcat {
+		for i, j := 0, len(ret.Sub)-1; i < j; i, j = i+1, j-1 {
+			ret.Sub[i], ret.Sub[j] = ret.Sub[j], ret.Sub[i]
+		}
+	}
+	return &ret
+}
+
+// Match reports whether the text contains any match of the regexp.
+func (m *ReverseSuffixMatcher) Match(b []byte) bool {
+	return m.FindAllIndex(b, 1) != nil
+}
+
+// FindIndex returns the location of the leftmost match of the regexp as regexp.Regexp.FindIndex does.
+func (m *ReverseSuffixMatcher) FindIndex(b []byte) []int {
+	if ret := m.FindAllIndex(b, 1); ret != nil {
+		return ret[0]
+	}
+	return nil
+}
+
+// FindAllIndex returns the locations of successive matches of the regexp as regexp.Regexp.FindAllIndex does.
+// A match which starts before the start found from an occurrence may end at a later occurrence,
+// so the start of the leftmost match is the minimum of the starts found from the occurrences.
+// The reverse scans are bounded by the maximum length of the matches and by reverseScanBudget,
+// so it runs in linear time of the text.
+//
+//nolint:gocyclo
+func (m *ReverseSuffixMatcher) FindAllIndex(b []byte, n int) [][]int {
+	// An invalid UTF-8 sequence matches U+FFFD which the suffixes do not contain.
+	if m.reverse == nil || !utf8.Valid(b) {
+		return m.re.FindAllIndex(b, n)
+	}
+	if n == 0 {
+		return nil
+	}
+	ends := m.suffixEnds(b)
+	if len(ends) == 0 {
+		return nil
+	}
+	// The reversed text which ends at the last occurrence.
+	var rev []byte
+	last := ends[len(ends)-1]
+	// starts caches the start found from each occurrence, -1 if none, -2 if unknown.
+	starts := make([]int, len(ends))
+	for i := range starts {
+		starts[i] = -2
+	}
+	var ret [][]int
+	pos, k := 0, 0
+	budget := reverseScanBudget * len(b)
+	for n < 0 || len(ret) < n {
+		// The matches are not empty, so they end after pos.
+		for k < len(ends) && ends[k] <= pos {
+			k++
+		}
+		best := -1
+		for i := k; i < len(ends) && best != pos; i++ {
+			if starts[i] == -2 || (starts[i] >= 0 && starts[i] < pos) {
+				if rev == nil {
+					rev = reverseText(b[:last])
+				}
+				lo := pos
+				if m.maxLen >= 0 && ends[i]-m.maxLen > lo {
+					lo = ends[i] - m.maxLen
+				}
+				if budget -= ends[i] - lo; budget < 0 {
+					return m.findAllIndexFrom(ret, b, pos, n)
+				}
+				starts[i] = -1
+				if loc := m.reverse.FindIndex(rev[last-ends[i] : last-lo]); loc != nil {
+					starts[i] = ends[i] - loc[1]
+				}
+			}
+			if s := starts[i]; s >= 0 && (best < 0 || s < best) {
+				best = s
+			}
+		}
+		if best < 0 {
+			break
+		}
+		loc := m.forward.FindIndex(b[best:])
+		ret = append(ret, []int{best, best + loc[1]})
+		pos = best + loc[1]
+	}
+	return ret
+}
+
+// findAllIndexFrom appends the matches of the regexp in the text after pos to ret until it has n matches.
+// The matches are not empty and the regexp has no empty width assertions, so the ones in b[pos:] are the same as the ones in b.
+func (m *ReverseSuffixMatcher) findAllIndexFrom(ret [][]int, b []byte, pos, n int) [][]int {
+	if n > 0 {
+		n -= len(ret)
+	}
+	for _, loc := range m.re.FindAllIndex(b[pos:], n) {
+		ret = append(ret, []int{pos + loc[0], pos + loc[1]})
+	}
+	return ret
+}
+
+// suffixEnds returns the sorted ends of the occurrences of the suffixes without duplicates.
+func (m *ReverseSuffixMatcher) suffixEnds(b []byte) []int {
+	var ret []int
+	add := func(end int) {
+		if len(ret) == 0 || ret[len(ret)-1] != end {
+			ret = append(ret, end)
+		}
+	}
+	if len(m.items) > 1 {
+		m.ac.Overlapping(b, func(o ahocorasick.Match) bool {
+			add(o.End)
+			return true
+		})
+		return ret
+	}
+	item := []byte(m.items[0])
+	for i := 0; ; i++ {
+		j := bytes.Index(b[i:], item)
+		if j < 0 {
+			return ret
+		}
+		i += j
+		add(i + len(item))
+	}
+}
+
+// reverseText returns the text whose runes are in the reverse order.
+func reverseText(b []byte) []byte {
+	ret := make([]byte, len(b))
+	for i := 0; i < len(b); {
+		_, size := utf8.DecodeRune(b[i:])
+		copy(ret[len(b)-i-size:], b[i:i+size])
+		i += size
+	}
+	return ret
+}
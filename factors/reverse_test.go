@@ -0,0 +1,127 @@
+package factors
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"testing"
+)
+
+func Test_reverseRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: `abc`, want: `cba`},
+		{pattern: `a(bc|de)+f`, want: `f(?:cb|ed)+a`},
+		{pattern: `[a-z]+@example\.com`, want: `moc\.elpmaxe@[a-z]+`},
+		{pattern: `(?i)ab`, want: `(?i:BA)`},
+		{pattern: `寿司`, want: `司寿`},
+	}
+	for _, tt := range tests {
+		re, err := syntax.Parse(tt.pattern, syntax.Perl)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		if got := reverseRegexp(re).String(); got != tt.want {
+			t.Errorf("reverseRegexp(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestReverseSuffixMatcher(t *testing.T) {
+	patterns := []string{
+		`[a-z0-9._]+@example\.com`,
+		`a.{3}z|bz`,
+		`(?i)ab+c`,
+		`x*ab|cab`,
+		`(a|ab)(c|bcd)`,
+		`[あ-ん]+です`,
+		`a+`,
+		`\bab`,
+		`\x{FFFD}b`,
+		`.*b`,
+		`a[a-z]*x`,
+		`a.{0,3}x`,
+	}
+	r := rand.New(rand.NewSource(1))
+	var texts []string
+	for i := 0; i < 300; i++ {
+		b := make([]byte, r.Intn(30))
+		for j := range b {
+			b[j] = "abcdxz1.@ \xff"[r.Intn(11)]
+		}
+		texts = append(texts, string(b))
+	}
+	texts = append(texts, "a1bzz", "foo@example.com bar.baz@example.com", "これはペンです", "xxabcab", "ABbBC")
+	// The dense occurrences of the suffixes exceed the budget of the reverse scans.
+	texts = append(texts, "b"+strings.Repeat("x", 100), "ax b"+strings.Repeat("x", 100)+"ax", strings.Repeat("bxax", 50))
+	for _, pattern := range patterns {
+		m, err := NewReverseSuffixMatcher(pattern)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		re := regexp.MustCompile(pattern)
+		for _, text := range texts {
+			b := []byte(text)
+			if got, want := m.Match(b), re.Match(b); got != want {
+				t.Errorf("%s: Match(%q) = %v, want %v", pattern, text, got, want)
+			}
+			if got, want := m.FindIndex(b), re.FindIndex(b); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: FindIndex(%q) = %v, want %v", pattern, text, got, want)
+			}
+			for _, n := range []int{-1, 0, 2} {
+				if got, want := m.FindAllIndex(b, n), re.FindAllIndex(b, n); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: FindAllIndex(%q, %d) = %v, want %v", pattern, text, n, got, want)
+				}
+			}
+		}
+	}
+	if _, err := NewReverseSuffixMatcher(`a(`); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func BenchmarkReverseSuffixMatcher_FindAllIndex(b *testing.B) {
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 1<<12)
+	copy(text[len(text)/2:], "mail to foo.bar@example.com")
+	pattern := `[a-z0-9._]+@example\.com`
+	b.Run("regexp", func(b *testing.B) {
+		re := regexp.MustCompile(pattern)
+		for i := 0; i < b.N; i++ {
+			re.FindAllIndex(text, -1)
+		}
+	})
+	b.Run("reverse", func(b *testing.B) {
+		m, err := NewReverseSuffixMatcher(pattern)
+		if err != nil {
+			b.Fatalf("unexpected error, %v", err)
+		}
+		for i := 0; i < b.N; i++ {
+			m.FindAllIndex(text, -1)
+		}
+	})
+}
+
+func BenchmarkReverseSuffixMatcher_FindAllIndex_dense(b *testing.B) {
+	text := []byte("b" + strings.Repeat("x", 32000))
+	pattern := `a[a-z]*x`
+	b.Run("regexp", func(b *testing.B) {
+		re := regexp.MustCompile(pattern)
+		for i := 0; i < b.N; i++ {
+			re.FindAllIndex(text, -1)
+		}
+	})
+	b.Run("reverse", func(b *testing.B) {
+		m, err := NewReverseSuffixMatcher(pattern)
+		if err != nil {
+			b.Fatalf("unexpected error, %v", err)
+		}
+		for i := 0; i < b.N; i++ {
+			m.FindAllIndex(text, -1)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package factors
+
+import (
+	"sort"
+)
+
+// suffixAutomaton is a suffix automaton of a rune string.
+type suffixAutomaton struct {
+	text   []rune
+	states []samState
+}
+
+type samState struct {
+	len  int
+	link int
+	end  int // the end position of the first occurrence in the text.
+	next map[rune]int
+}
+
+func newSuffixAutomaton(text []rune) *suffixAutomaton {
+	sa := &suffixAutomaton{
+		text:   text,
+		states: make([]samState, 1, 2*len(text)+1),
+	}
+	sa.states[0] = samState{link: -1, end: -1, next: map[rune]int{}}
+	last := 0
+	for i, r := range text {
+		cur := len(sa.states)
+		sa.states = append(sa.states, samState{len: sa.states[last].len + 1, end: i, next: map[rune]int{}})
+		p := last
+		for p != -1 {
+			if _, ok := sa.states[p].next[r]; ok {
+				break
+			}
+			sa.states[p].next[r] = cur
+			p = sa.states[p].link
+		}
+		switch {
+		case p == -1:
+			sa.states[cur].link = 0
+		case sa.states[p].len+1 == sa.states[sa.states[p].next[r]].len:
+			sa.states[cur].link = sa.states[p].next[r]
+		default:
+			q := sa.states[p].next[r]
+			clone := len(sa.states)
+			next := make(map[rune]int, len(sa.states[q].next))
+			for k, v := range sa.states[q].next {
+				next[k] = v
+			}
+			sa.states = append(sa.states, samState{
+				len:  sa.states[p].len + 1,
+				link: sa.states[q].link,
+				end:  sa.states[q].end,
+				next: next,
+			})
+			for p != -1 && sa.states[p].next[r] == q {
+				sa.states[p].next[r] = clone
+				p = sa.states[p].link
+			}
+			sa.states[q].link = clone
+			sa.states[cur].link = clone
+		}
+		last = cur
+	}
+	return sa
+}
+
+// longestCommonSubstring returns the longest substring common to all the items.
+// It compares runes, so the result never splits a UTF-8 sequence.
+// If there are some longest common substrings, it returns the smallest one in lexical order.
+//
+//nolint:gocyclo
+func longestCommonSubstring(items ...string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	// Build the automaton of the shortest item.
+	base := 0
+	for i := range items {
+		if len(items[i]) < len(items[base]) {
+			base = i
+		}
+	}
+	sa := newSuffixAutomaton([]rune(items[base]))
+	order := make([]int, len(sa.states))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return sa.states[order[i]].len > sa.states[order[j]].len
+	})
+	best := make([]int, len(sa.states))
+	for i := range best {
+		best[i] = sa.states[i].len
+	}
+	cur := make([]int, len(sa.states))
+	for i, item := range items {
+		if i == base {
+			continue
+		}
+		for j := range cur {
+			cur[j] = 0
+		}
+		p, l := 0, 0
+		for _, r := range item {
+			for p != 0 && !sa.has(p, r) {
+				p = sa.states[p].link
+				l = sa.states[p].len
+			}
+			if q, ok := sa.states[p].next[r]; ok {
+				p = q
+				l++
+			}
+			if cur[p] < l {
+				cur[p] = l
+			}
+		}
+		// A match at a state also matches at its suffix links.
+		for _, v := range order {
+			if link := sa.states[v].link; link > 0 && cur[v] > 0 {
+				if m := minInt(cur[v], sa.states[link].len); cur[link] < m {
+					cur[link] = m
+				}
+			}
+		}
+		for j := range best {
+			best[j] = minInt(best[j], cur[j])
+		}
+	}
+	var ret string
+	found := false
+	for v := 1; v < len(sa.states); v++ {
+		if best[v] == 0 {
+			continue
+		}
+		end := sa.states[v].end + 1
+		s := string(sa.text[end-best[v] : end])
+		if !found || len([]rune(s)) > len([]rune(ret)) || (len([]rune(s)) == len([]rune(ret)) && s < ret) {
+			ret = s
+			found = true
+		}
+	}
+	return ret
+}
+
+func (sa *suffixAutomaton) has(p int, r rune) bool {
+	_, ok := sa.states[p].next[r]
+	return ok
+}
+
+func minInt(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
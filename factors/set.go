@@ -3,110 +3,191 @@ package factors
 import (
 	"sort"
 	"strings"
+
+	"github.com/ikawaha/factors/factors/ahocorasick"
 )
 
-const theta = "θ"
+const (
+	theta = "θ"
+	// trieThreshold is the number of items over which a set keeps its items in a trie.
+	trieThreshold = 1000
+	// pairwiseThreshold is the number of items up to which a set compares every pair of the items
+	// to drop the redundant fragments, instead of building an Aho-Corasick automaton.
+	pairwiseThreshold = 16
+)
 
-type stringSet map[string]struct{}
+// stringSet is a sorted slice of strings without duplicates.
+// It is never modified after it is built, so it can be shared among sets.
+type stringSet []string
 
 func newStringSet(items ...string) stringSet {
-	ret := stringSet{}
-	for _, v := range items {
-		ret[v] = struct{}{}
+	if len(items) == 0 {
+		return nil
 	}
-	return ret
+	ret := make(stringSet, len(items))
+	copy(ret, items)
+	sort.Strings(ret)
+	return ret.dedup()
+}
+
+// dedup removes duplicates of the sorted strings in place.
+func (ss stringSet) dedup() stringSet {
+	w := 0
+	for _, v := range ss {
+		if w == 0 || ss[w-1] != v {
+			ss[w] = v
+			w++
+		}
+	}
+	return ss[:w]
+}
+
+// contains returns true if the set has the item.
+func (ss stringSet) contains(item string) bool {
+	i := sort.SearchStrings(ss, item)
+	return i < len(ss) && ss[i] == item
+}
+
+// filter returns the strings which f returns true, it shares the strings if nothing is dropped.
+func (ss stringSet) filter(f func(i int) bool) stringSet {
+	for i := range ss {
+		if f(i) {
+			continue
+		}
+		ret := make(stringSet, i, len(ss)-1)
+		copy(ret, ss[:i])
+		for j := i + 1; j < len(ss); j++ {
+			if f(j) {
+				ret = append(ret, ss[j])
+			}
+		}
+		if len(ret) == 0 {
+			return nil
+		}
+		return ret
+	}
+	return ss
 }
 
 // Set represents a set of necessary factors.
+// A large set keeps its items in a trie sharing common prefixes and sub graphs,
+// and materializes the strings only when they are required.
 type Set struct {
 	items      stringSet
+	trie       *trieNode
 	minimumLen int
 	infinite   bool
+	src        map[string][]Span // sources of the items, nil if they are not tracked.
 }
 
 // NewSet creates a set initialized given items.
 func NewSet(items ...string) Set {
 	var ret Set
-	for _, v := range items {
-		ret.Add(v)
+	if len(items) == 0 {
+		return ret
+	}
+	ret.items = newStringSet(items...)
+	for i, v := range items {
+		if i == 0 || len(v) < ret.minimumLen {
+			ret.minimumLen = len(v)
+		}
 	}
 	return ret
 }
 
 // Items returns (sorted) items of the set.
 func (s Set) Items() []string {
-	if s.infinite || len(s.items) == 0 {
+	if s.infinite {
 		return nil
 	}
-	ret := make([]string, 0, len(s.items))
-	for k := range s.items {
-		ret = append(ret, k)
+	if s.trie != nil {
+		return trieItems(s.trie)
 	}
-	sort.Strings(ret)
+	if len(s.items) == 0 {
+		return nil
+	}
+	ret := make([]string, len(s.items))
+	copy(ret, s.items)
 	return ret
 }
 
+// list returns the sorted items without copying, the caller must not modify them.
+func (s Set) list() stringSet {
+	if s.infinite {
+		return nil
+	}
+	if s.trie != nil {
+		return trieItems(s.trie)
+	}
+	return s.items
+}
+
 // Add adds a item to the set.
 func (s *Set) Add(item string) {
 	if s.infinite {
 		return
 	}
-	if s.items == nil {
-		s.items = stringSet{}
+	empty := s.trie == nil && len(s.items) == 0
+	if s.trie != nil {
+		s.trie = trieUnion(s.trie, newTrie([]string{item}))
+	} else if i := sort.SearchStrings(s.items, item); i == len(s.items) || s.items[i] != item {
+		// copy on write, the items may be shared with other sets.
+		items := make(stringSet, len(s.items)+1)
+		copy(items, s.items[:i])
+		items[i] = item
+		copy(items[i+1:], s.items[i:])
+		s.items = items
 	}
-	s.items[item] = struct{}{}
-	if s.minimumLen == 0 || len(item) < s.minimumLen {
+	if empty || len(item) < s.minimumLen {
 		s.minimumLen = len(item)
 	}
 }
 
-// longest common substring
-func longestCommonSubstring(x, y string) string {
-	if len(x) == 0 || len(y) == 0 {
+// LongestCommon returns the longest common substring of items in the set.
+// If there are some longest common substrings, it returns the smallest one in lexical order.
+func (s Set) LongestCommon() string {
+	if s.size() == 0 || s.infinite {
 		return ""
 	}
-	var max int
-	matrix := make([][]int, len(x))
-	for i := range matrix {
-		matrix[i] = make([]int, len(y))
-	}
-	var p int
-	for i := 0; i < len(x); i++ {
-		for j := 0; j < len(y); j++ {
-			if x[i] == y[j] {
-				if i == 0 || j == 0 {
-					matrix[i][j] = 1
-					if max < matrix[i][j] {
-						max = matrix[i][j]
-						p = i
-					}
-					continue
-				}
-				matrix[i][j] = matrix[i-1][j-1] + 1
-				if max < matrix[i][j] {
-					max = matrix[i][j]
-					p = i
-				}
-			}
+	return longestCommonSubstring(s.list()...)
+}
+
+// LongestCommonPrefix returns the longest common prefix of items in the set.
+func (s Set) LongestCommonPrefix() string {
+	if s.size() == 0 || s.infinite {
+		return ""
+	}
+	if s.trie != nil {
+		return trieLongestCommonPrefix(s.trie)
+	}
+	// The common prefix of the sorted items is the one of the first and the last.
+	first, last := []rune(s.items[0]), s.items[len(s.items)-1]
+	i := 0
+	for _, r := range last {
+		if i == len(first) || first[i] != r {
+			break
 		}
+		i++
 	}
-	return x[(p+1)-max : p+1]
+	return string(first[:i])
 }
 
-// LongestCommon returns the longest common substring of items in the set.
-func (s Set) LongestCommon() string {
-	if len(s.items) == 0 || s.infinite {
+// LongestCommonSuffix returns the longest common suffix of items in the set.
+func (s Set) LongestCommonSuffix() string {
+	if s.size() == 0 || s.infinite {
 		return ""
 	}
-	items := s.Items()
-	ret := items[0]
-	for i := 1; i < len(items); i++ {
-		ret = longestCommonSubstring(ret, items[i])
-		if ret == "" {
-			return ""
+	items := s.list()
+	ret := []rune(items[0])
+	for _, v := range items[1:] {
+		rs := []rune(v)
+		i := 0
+		for i < len(ret) && i < len(rs) && ret[len(ret)-1-i] == rs[len(rs)-1-i] {
+			i++
 		}
+		ret = ret[len(ret)-i:]
 	}
-	return ret
+	return string(ret)
 }
 
 // Clear clears the set.
@@ -114,6 +195,8 @@ func (s *Set) Clear() {
 	s.infinite = false
 	s.minimumLen = 0
 	s.items = nil
+	s.trie = nil
+	s.src = nil
 }
 
 // SetInfinite sets this set infinite.
@@ -121,6 +204,8 @@ func (s *Set) SetInfinite() {
 	s.infinite = true
 	s.minimumLen = 0
 	s.items = nil
+	s.trie = nil
+	s.src = nil
 }
 
 // Infinite returns true if this set is infinite.
@@ -130,23 +215,27 @@ func (s Set) Infinite() bool {
 
 // DropRedundantPrefix drops items which has prefix of other item in this set.
 func (s *Set) DropRedundantPrefix() {
-	if s.infinite || len(s.items) == 0 {
+	if s.infinite || s.size() == 0 {
 		return
 	}
-	ps := make([]string, 0, len(s.items))
-	for k := range s.items {
-		ps = append(ps, k)
+	if s.trie != nil {
+		s.trie = trieDropRedundantPrefix(s.trie)
+		s.pruneSource()
+		return
 	}
-	sort.Strings(ps)
-	items := make([]string, 0, len(ps))
-	items = append(items, ps[0])
-	for i := 1; i < len(ps); i++ {
-		if strings.HasPrefix(ps[i], items[len(items)-1]) {
-			continue
+	// In the sorted items, an item which has a prefix follows the last kept item.
+	last := s.items[0]
+	s.items = s.items.filter(func(i int) bool {
+		if i == 0 {
+			return true
 		}
-		items = append(items, ps[i])
-	}
-	s.items = newStringSet(items...)
+		if strings.HasPrefix(s.items[i], last) {
+			return false
+		}
+		last = s.items[i]
+		return true
+	})
+	s.pruneSource()
 }
 
 func sortByRevertedString(s []string) {
@@ -168,47 +257,107 @@ func sortByRevertedString(s []string) {
 
 // DropRedundantSuffix drops items which has suffix of other item in this set.
 func (s *Set) DropRedundantSuffix() {
-	if s.infinite || len(s.items) == 0 {
+	if s.infinite || s.size() == 0 {
 		return
 	}
-	ss := make([]string, 0, len(s.items))
-	for k := range s.items {
-		ss = append(ss, k)
+	if s.trie != nil {
+		s.trie = trieDropRedundantSuffix(s.trie)
+		s.pruneSource()
+		return
 	}
+	ss := s.Items()
 	sortByRevertedString(ss)
-	items := make([]string, 0, len(ss))
-	items = append(items, ss[0])
+	items := ss[:1]
 	for i := 1; i < len(ss); i++ {
 		if strings.HasSuffix(ss[i], items[len(items)-1]) {
 			continue
 		}
 		items = append(items, ss[i])
 	}
-	s.items = newStringSet(items...)
+	if len(items) == len(ss) {
+		return
+	}
+	s.setItems(items)
 }
 
 // DropRedundantFragment drops items which contains of other item in this set.
+// Every item contains the empty string, so a set with the empty string becomes {""}, i.e. no requirement.
 func (s *Set) DropRedundantFragment() {
-	fs := s.Items()
-loop:
-	for i := 0; i < len(fs); i++ {
-		for j := 0; j < len(fs); j++ {
-			if i == j || fs[i] == "" {
-				continue
-			}
-			if strings.Contains(fs[j], fs[i]) {
-				fs[j] = ""
-				continue loop
+	if s.infinite || s.size() == 0 {
+		return
+	}
+	if s.Contains("") {
+		s.setItems([]string{""})
+		return
+	}
+	if s.trie != nil {
+		s.trie = trieDropRedundantFragment(s.trie)
+		s.pruneSource()
+		return
+	}
+	if len(s.items) <= pairwiseThreshold {
+		s.items = s.items.filter(func(j int) bool {
+			for i, v := range s.items {
+				if i != j && strings.Contains(s.items[j], v) {
+					return false
+				}
 			}
-		}
+			return true
+		})
+		s.pruneSource()
+		return
 	}
-	items := make([]string, 0, len(fs))
-	for _, v := range fs {
-		if v != "" {
-			items = append(items, v)
-		}
+	// Find the items containing other items by an Aho-Corasick automaton of the items,
+	// instead of comparing every pair of the items.
+	ac := ahocorasick.New(s.items)
+	s.items = s.items.filter(func(j int) bool {
+		keep := true
+		ac.OverlappingString(s.items[j], func(m ahocorasick.Match) bool {
+			keep = m.Pattern == j
+			return keep
+		})
+		return keep
+	})
+	s.pruneSource()
+}
+
+// setItems replaces the items of the set with the given strings which it owns,
+// and keeps them in a trie if the set is large.
+func (s *Set) setItems(items []string) {
+	s.items = nil
+	s.trie = nil
+	if len(items) > trieThreshold {
+		s.trie = newTrie(items)
+		s.pruneSource()
+		return
+	}
+	if len(items) == 0 {
+		s.src = nil
+		return
 	}
-	s.items = newStringSet(items...)
+	ss := stringSet(items)
+	sort.Strings(ss)
+	s.items = ss.dedup()
+	s.pruneSource()
+}
+
+// size returns the number of the items.
+func (s Set) size() int {
+	if s.trie != nil {
+		return trieLen(s.trie)
+	}
+	return len(s.items)
+}
+
+// asTrie returns a trie of the items, nil if the set is empty.
+func (s Set) asTrie() *trieNode {
+	if s.trie != nil {
+		return s.trie
+	}
+	if len(s.items) == 0 {
+		return nil
+	}
+	return buildTrie(s.items, 0)
 }
 
 // Len returns a size of this set.
@@ -216,7 +365,7 @@ func (s Set) Len() int {
 	if s.infinite {
 		return -1
 	}
-	return len(s.items)
+	return s.size()
 }
 
 // String returns string represents of this set.
@@ -224,7 +373,7 @@ func (s Set) String() string {
 	if s.infinite {
 		return theta
 	}
-	return "{" + strings.Join(s.Items(), ", ") + "}"
+	return "{" + strings.Join(s.list(), ", ") + "}"
 }
 
 // UnionSet returns a union set of x and y.
@@ -234,17 +383,44 @@ func UnionSet(x, y Set) Set {
 	if ret.infinite {
 		return ret
 	}
-	ret.items = make(stringSet, len(x.items)+len(y.items))
-	for k := range x.items {
-		ret.Add(k)
-	}
-	for k := range y.items {
-		ret.Add(k)
+	switch {
+	case x.trie != nil || y.trie != nil || len(x.items)+len(y.items) > trieThreshold:
+		ret.trie = trieUnion(x.asTrie(), y.asTrie())
+	case len(x.items) == 0:
+		ret.items = y.items
+	case len(y.items) == 0:
+		ret.items = x.items
+	default:
+		// merge the sorted items.
+		items := make(stringSet, 0, len(x.items)+len(y.items))
+		i, j := 0, 0
+		for i < len(x.items) && j < len(y.items) {
+			switch a, b := x.items[i], y.items[j]; {
+			case a < b:
+				items = append(items, a)
+				i++
+			case a > b:
+				items = append(items, b)
+				j++
+			default:
+				items = append(items, a)
+				i++
+				j++
+			}
+		}
+		items = append(items, x.items[i:]...)
+		ret.items = append(items, y.items[j:]...)
 	}
-	ret.minimumLen = x.minimumLen
-	if x.minimumLen > y.minimumLen {
+	// The minimum length of an empty set is meaningless.
+	switch {
+	case x.size() == 0:
+		ret.minimumLen = y.minimumLen
+	case y.size() == 0 || x.minimumLen <= y.minimumLen:
+		ret.minimumLen = x.minimumLen
+	default:
 		ret.minimumLen = y.minimumLen
 	}
+	ret.src = mergeSource(ret, x, y)
 	return ret
 }
 
@@ -256,13 +432,47 @@ func CrossSet(x, y Set) Set {
 	if ret.infinite {
 		return ret
 	}
-	ret.items = make(stringSet, len(x.items)*len(y.items))
-	for k0 := range x.items {
-		for k1 := range y.items {
-			ret.items[k0+k1] = struct{}{}
+	ret.minimumLen = x.minimumLen + y.minimumLen
+	if x.size() == 0 || y.size() == 0 {
+		return ret
+	}
+	if x.trie != nil || y.trie != nil || len(x.items)*len(y.items) > trieThreshold {
+		ret.trie = trieCross(x.asTrie(), y.asTrie())
+		ret.src = crossSource(x, y)
+		return ret
+	}
+	// Concatenate the strings into an arena, and slice the items out of it.
+	var n int
+	for _, v := range x.items {
+		n += len(v) * len(y.items)
+	}
+	for _, v := range y.items {
+		n += len(v) * len(x.items)
+	}
+	var b strings.Builder
+	b.Grow(n)
+	for _, v0 := range x.items {
+		for _, v1 := range y.items {
+			b.WriteString(v0)
+			b.WriteString(v1)
 		}
 	}
-	ret.minimumLen = x.minimumLen + y.minimumLen
+	arena := b.String()
+	items := make(stringSet, 0, len(x.items)*len(y.items))
+	var pos int
+	for _, v0 := range x.items {
+		for _, v1 := range y.items {
+			end := pos + len(v0) + len(v1)
+			items = append(items, arena[pos:end])
+			pos = end
+		}
+	}
+	// The concatenations keep the order unless an item of x is a prefix of another.
+	if !sort.StringsAreSorted(items) {
+		sort.Strings(items)
+	}
+	ret.items = items.dedup()
+	ret.src = crossSource(x, y)
 	return ret
 }
 
@@ -270,15 +480,213 @@ func CrossSet(x, y Set) Set {
 func BestSet(arg Set, args ...Set) Set {
 	best := arg
 	for _, v := range args {
-		if best.minimumLen > v.minimumLen {
-			continue
+		if better(v, best) {
+			best = v
 		}
-		if best.minimumLen == v.minimumLen {
-			if len(best.items) < len(v.items) {
-				continue
-			}
-		}
-		best = v
 	}
 	return best
 }
+
+// better returns true if BestSet prefers x to y which precedes x,
+// i.e. the shortest item of x is longer, or x has fewer or the same number of items if the shortest items are of the same length.
+func better(x, y Set) bool {
+	if y.minimumLen > x.minimumLen {
+		return false
+	}
+	if y.minimumLen == x.minimumLen {
+		if y.size() < x.size() {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectSet returns an intersection set of x and y.
+// θ is the set of every string, so the intersection of θ and y is y.
+func IntersectSet(x, y Set) Set {
+	switch {
+	case x.infinite:
+		return y
+	case y.infinite:
+		return x
+	}
+	ret := newSetOfSorted(mergeStrings(x.list(), y.list(), func(inX, inY bool) bool {
+		return inX && inY
+	}))
+	ret.src = mergeSource(ret, x, y)
+	return ret
+}
+
+// DifferenceSet returns a set of the items of x which are not in y.
+// Nothing remains after removing θ, and θ remains θ after removing a finite set.
+func DifferenceSet(x, y Set) Set {
+	switch {
+	case y.infinite:
+		return Set{}
+	case x.infinite:
+		return x
+	}
+	ret := newSetOfSorted(mergeStrings(x.list(), y.list(), func(inX, inY bool) bool {
+		return inX && !inY
+	}))
+	ret.src = mergeSource(ret, x)
+	return ret
+}
+
+// newSetOfSorted creates a set of the sorted items without duplicates which it owns.
+func newSetOfSorted(items []string) Set {
+	var ret Set
+	ret.setItems(items)
+	for i, v := range items {
+		if i == 0 || len(v) < ret.minimumLen {
+			ret.minimumLen = len(v)
+		}
+	}
+	return ret
+}
+
+// mergeStrings merges the sorted strings x and y, and returns the strings which keep reports true.
+func mergeStrings(x, y []string, keep func(inX, inY bool) bool) []string {
+	var ret []string
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		var v string
+		var inX, inY bool
+		switch {
+		case j == len(y) || (i < len(x) && x[i] < y[j]):
+			v, inX = x[i], true
+			i++
+		case i == len(x) || x[i] > y[j]:
+			v, inY = y[j], true
+			j++
+		default:
+			v, inX, inY = x[i], true, true
+			i++
+			j++
+		}
+		if keep(inX, inY) {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// Equal returns true if the sets have the same items. θ equals only θ.
+func (s Set) Equal(t Set) bool {
+	if s.infinite || t.infinite {
+		return s.infinite == t.infinite
+	}
+	x, y := s.list(), t.list()
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSubsetOf returns true if every item of the set is in t.
+// Every set is a subset of θ, and θ is not a subset of any finite set.
+func (s Set) IsSubsetOf(t Set) bool {
+	switch {
+	case t.infinite:
+		return true
+	case s.infinite:
+		return false
+	}
+	return len(mergeStrings(s.list(), t.list(), func(inX, inY bool) bool {
+		return inX && !inY
+	})) == 0
+}
+
+// Contains returns true if the set has the item. θ contains every string.
+func (s Set) Contains(item string) bool {
+	if s.infinite {
+		return true
+	}
+	if s.trie != nil {
+		return trieContains(s.trie, item)
+	}
+	return s.items.contains(item)
+}
+
+// MatchesText returns true if the text contains any item of the set.
+// θ contains the empty string, so it matches every text.
+// It builds a matcher of the items on every call, use Matcher to search many texts.
+func (s Set) MatchesText(text []byte) bool {
+	return s.Matcher().MatchesText(text)
+}
+
+// FirstMatch returns the leftmost item of the set in the text and its index.
+// If some items start at the index, it returns the longest one.
+// It returns the empty string and -1 if the text contains no item,
+// and the empty string and 0 for θ.
+// It builds a matcher of the items on every call, use Matcher to search many texts.
+func (s Set) FirstMatch(text []byte) (item string, index int) {
+	return s.Matcher().FirstMatch(text)
+}
+
+// SetMatcher finds the items of a set in texts with an Aho-Corasick automaton of the items.
+// It is never modified after it is created, so it is safe for concurrent use.
+type SetMatcher struct {
+	infinite bool
+	items    []string
+	ac       *ahocorasick.Automaton
+	// empty is true if the set has the empty string, which every text contains at 0.
+	empty  bool
+	maxLen int
+}
+
+// Matcher returns a matcher of the items of the set, which builds the automaton once to search many texts.
+// Modifying the set after the call does not affect the matcher.
+func (s Set) Matcher() *SetMatcher {
+	ret := &SetMatcher{infinite: s.infinite}
+	if s.infinite || s.size() == 0 {
+		return ret
+	}
+	ret.items = s.list()
+	for _, v := range ret.items {
+		if v == "" {
+			ret.empty = true
+		}
+		if len(v) > ret.maxLen {
+			ret.maxLen = len(v)
+		}
+	}
+	ret.ac = ahocorasick.New(ret.items)
+	return ret
+}
+
+// MatchesText returns true if the text contains any item of the set.
+func (m *SetMatcher) MatchesText(text []byte) bool {
+	_, index := m.FirstMatch(text)
+	return index >= 0
+}
+
+// FirstMatch returns the leftmost item of the set in the text and its index as Set.FirstMatch does.
+func (m *SetMatcher) FirstMatch(text []byte) (item string, index int) {
+	if m.infinite {
+		return "", 0
+	}
+	index = -1
+	if m.empty {
+		index = 0
+	}
+	if m.ac == nil {
+		return "", index
+	}
+	m.ac.Overlapping(text, func(v ahocorasick.Match) bool {
+		if index >= 0 && v.End-m.maxLen > index {
+			// No more items start at or before the index.
+			return false
+		}
+		if index < 0 || v.Start < index || (v.Start == index && len(m.items[v.Pattern]) > len(item)) {
+			item, index = m.items[v.Pattern], v.Start
+		}
+		return true
+	})
+	return item, index
+}
@@ -1,7 +1,9 @@
 package factors
 
 import (
+	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -37,6 +39,26 @@ func TestCrossSet(t *testing.T) {
 	}
 }
 
+func TestUnionSet_minimumLen(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y Set
+		want int
+	}{
+		{name: "empty set", x: NewSet(), y: NewSet("abc"), want: 3},
+		{name: "to empty set", x: NewSet("abc"), y: NewSet(), want: 3},
+		{name: "empty string", x: NewSet("abc"), y: NewSet(""), want: 0},
+		{name: "shorter", x: NewSet("abc"), y: NewSet("de"), want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnionSet(tt.x, tt.y).minimumLen; got != tt.want {
+				t.Errorf("UnionSet().minimumLen = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestNewSet(t *testing.T) {
 	type args struct {
 		items []string
@@ -64,11 +86,22 @@ func TestNewSet(t *testing.T) {
 			want: Set{
 				infinite:   false,
 				minimumLen: 5,
-				items: map[string]struct{}{
-					"hello":   {},
-					"goodbye": {},
+				items:      stringSet{"goodbye", "hello"},
+			},
+		},
+		{
+			name: "new with the empty string",
+			args: args{
+				items: []string{
+					"",
+					"hello",
 				},
 			},
+			want: Set{
+				infinite:   false,
+				minimumLen: 0,
+				items:      stringSet{"", "hello"},
+			},
 		},
 	}
 	for _, tt := range tests {
@@ -108,9 +141,7 @@ func TestSet_Add(t *testing.T) {
 			want: Set{
 				infinite:   false,
 				minimumLen: 5,
-				items: stringSet{
-					"hello": {},
-				},
+				items:      stringSet{"hello"},
 			},
 		},
 		{
@@ -118,9 +149,7 @@ func TestSet_Add(t *testing.T) {
 			fields: fields{
 				undef:      false,
 				minimumLen: 7,
-				items: stringSet{
-					"goodbye": {},
-				},
+				items:      stringSet{"goodbye"},
 			},
 			args: args{
 				item: "hello",
@@ -128,10 +157,23 @@ func TestSet_Add(t *testing.T) {
 			want: Set{
 				infinite:   false,
 				minimumLen: 5,
-				items: stringSet{
-					"hello":   {},
-					"goodbye": {},
-				},
+				items:      stringSet{"goodbye", "hello"},
+			},
+		},
+		{
+			name: "add an item to a set with the empty string",
+			fields: fields{
+				undef:      false,
+				minimumLen: 0,
+				items:      stringSet{""},
+			},
+			args: args{
+				item: "hello",
+			},
+			want: Set{
+				infinite:   false,
+				minimumLen: 0,
+				items:      stringSet{"", "hello"},
 			},
 		},
 		{
@@ -139,10 +181,7 @@ func TestSet_Add(t *testing.T) {
 			fields: fields{
 				undef:      false,
 				minimumLen: 5,
-				items: stringSet{
-					"goodbye": {},
-					"hello":   {},
-				},
+				items:      stringSet{"goodbye", "hello"},
 			},
 			args: args{
 				item: "hello",
@@ -150,10 +189,7 @@ func TestSet_Add(t *testing.T) {
 			want: Set{
 				infinite:   false,
 				minimumLen: 5,
-				items: stringSet{
-					"hello":   {},
-					"goodbye": {},
-				},
+				items:      stringSet{"goodbye", "hello"},
 			},
 		},
 	}
@@ -188,11 +224,7 @@ func TestSet_Clear(t *testing.T) {
 			fields: fields{
 				undef:      true,
 				minimumLen: 5,
-				items: stringSet{
-					"hello":   {},
-					"goodbye": {},
-					"aloha":   {},
-				},
+				items:      stringSet{"aloha", "goodbye", "hello"},
 			},
 			want: Set{},
 		},
@@ -233,10 +265,7 @@ func TestSet_Items(t *testing.T) {
 			fields: fields{
 				undef:      false,
 				minimumLen: 5,
-				items: stringSet{
-					"hello":   {},
-					"goodbye": {},
-				},
+				items:      stringSet{"goodbye", "hello"},
 			},
 			want: []string{"goodbye", "hello"},
 		},
@@ -245,10 +274,7 @@ func TestSet_Items(t *testing.T) {
 			fields: fields{
 				undef:      true,
 				minimumLen: 5,
-				items: stringSet{
-					"hello":   {},
-					"goodbye": {},
-				},
+				items:      stringSet{"goodbye", "hello"},
 			},
 			want: nil,
 		},
@@ -288,10 +314,7 @@ func TestSet_Size(t *testing.T) {
 			fields: fields{
 				undef:      false,
 				minimumLen: 0,
-				items: stringSet{
-					"hello":   {},
-					"goodbye": {},
-				},
+				items:      stringSet{"goodbye", "hello"},
 			},
 			want: 2,
 		},
@@ -336,10 +359,7 @@ func TestSet_String(t *testing.T) {
 		{
 			name: "string representation of a set",
 			fields: fields{
-				items: stringSet{
-					"hello":   {},
-					"goodbye": {},
-				},
+				items: stringSet{"goodbye", "hello"},
 			},
 			want: "{goodbye, hello}",
 		},
@@ -392,6 +412,14 @@ func Test_longestCommonSubstring(t *testing.T) {
 			},
 			want: "ADABR",
 		},
+		{
+			name: "rune safe",
+			args: args{
+				x: "あいう",
+				y: "かいき",
+			},
+			want: "い",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -436,6 +464,22 @@ func TestSet_LongestCommon(t *testing.T) {
 			},
 			want: "appl",
 		},
+		{
+			name: "not found by pairwise folding {abxcd, cdyab, cdz}",
+			fields: fields{
+				items:      newStringSet("abxcd", "cdyab", "cdz"),
+				minimumLen: 3,
+			},
+			want: "cd",
+		},
+		{
+			name: "{東京都, 京都府, 東京}",
+			fields: fields{
+				items:      newStringSet("東京都", "京都府", "東京"),
+				minimumLen: 6,
+			},
+			want: "京",
+		},
 		{
 			name: "no common substring {hello, aloha, 123, 456}",
 			fields: fields{
@@ -458,3 +502,343 @@ func TestSet_LongestCommon(t *testing.T) {
 		})
 	}
 }
+
+func TestSet_LongestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		set  Set
+		want string
+	}{
+		{
+			name: "empty set",
+			set:  NewSet(),
+			want: "",
+		},
+		{
+			name: "infinite set",
+			set:  Set{infinite: true},
+			want: "",
+		},
+		{
+			name: "{apple, apply, applet}",
+			set:  NewSet("apple", "apply", "applet"),
+			want: "appl",
+		},
+		{
+			name: "{あいう, あいえ}",
+			set:  NewSet("あいう", "あいえ"),
+			want: "あい",
+		},
+		{
+			name: "trie",
+			set:  CrossSet(NewSet(keywords("あい", 40)...), NewSet(keywords("", 40)...)),
+			want: "あい0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.set.LongestCommonPrefix(); got != tt.want {
+				t.Errorf("LongestCommonPrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet_LongestCommonSuffix(t *testing.T) {
+	tests := []struct {
+		name string
+		set  Set
+		want string
+	}{
+		{
+			name: "empty set",
+			set:  NewSet(),
+			want: "",
+		},
+		{
+			name: "{walking, talking, king}",
+			set:  NewSet("walking", "talking", "king"),
+			want: "king",
+		},
+		{
+			name: "{うあい, えあい}",
+			set:  NewSet("うあい", "えあい"),
+			want: "あい",
+		},
+		{
+			name: "no common suffix",
+			set:  NewSet("abc", "abd"),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.set.LongestCommonSuffix(); got != tt.want {
+				t.Errorf("LongestCommonSuffix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet_DropRedundantFragment(t *testing.T) {
+	tests := []struct {
+		name string
+		set  Set
+		want Set
+	}{
+		{name: "empty set", set: NewSet(), want: NewSet()},
+		{name: "infinite set", set: thetaSet(), want: thetaSet()},
+		{name: "no redundant", set: NewSet("abc", "bcd", "xyz"), want: NewSet("abc", "bcd", "xyz")},
+		{name: "contains", set: NewSet("abc", "b", "xbx"), want: NewSet("b")},
+		{name: "empty item", set: NewSet("", "abc"), want: NewSet("")},
+		{name: "chain", set: NewSet("a", "ab", "abc"), want: NewSet("a")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.set.DropRedundantFragment()
+			if !reflect.DeepEqual(tt.set.Items(), tt.want.Items()) || tt.set.Infinite() != tt.want.Infinite() {
+				t.Errorf("DropRedundantFragment() = %v, want %v", tt.set, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet_DropRedundantFragment_random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for n := 0; n < 200; n++ {
+		items := make([]string, rnd.Intn(30))
+		for i := range items {
+			b := make([]byte, rnd.Intn(5))
+			for j := range b {
+				b[j] = "abc"[rnd.Intn(3)]
+			}
+			items[i] = string(b)
+		}
+		got := NewSet(items...)
+		got.DropRedundantFragment()
+		want := dropRedundantFragmentNaive(NewSet(items...).Items())
+		if !reflect.DeepEqual(got.Items(), want) {
+			t.Fatalf("DropRedundantFragment(%v) = %v, want %v", items, got.Items(), want)
+		}
+	}
+}
+
+// dropRedundantFragmentNaive is the quadratic implementation which compares every pair of the items.
+func dropRedundantFragmentNaive(fs []string) []string {
+	var ret []string
+loop:
+	for i, v := range fs {
+		for j, u := range fs {
+			if i != j && strings.Contains(v, u) {
+				if u == "" {
+					return []string{""}
+				}
+				continue loop
+			}
+		}
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+func BenchmarkUnionSet(b *testing.B) {
+	x := NewSet(keywords("apple", 100)...)
+	y := NewSet(keywords("apply", 100)...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		UnionSet(x, y)
+	}
+}
+
+func BenchmarkCrossSet(b *testing.B) {
+	x := NewSet(keywords("GET /", 20)...)
+	y := NewSet(keywords("/api/v", 30)...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CrossSet(x, y)
+	}
+}
+
+func BenchmarkBestSet(b *testing.B) {
+	x := NewSet(keywords("apple", 100)...)
+	y := NewSet(keywords("apply", 50)...)
+	z := NewSet(keywords("applet", 10)...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BestSet(x, y, z)
+	}
+}
+
+func BenchmarkSet_DropRedundantFragment(b *testing.B) {
+	items := append(keywords("apple", 500), keywords("pineapple", 500)...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := NewSet(items...)
+		s.DropRedundantFragment()
+	}
+}
+
+func thetaSet() Set {
+	var s Set
+	s.SetInfinite()
+	return s
+}
+
+func TestIntersectSet(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y Set
+		want Set
+	}{
+		{name: "empty", x: NewSet(), y: NewSet("a"), want: NewSet()},
+		{name: "common", x: NewSet("a", "b", "c"), y: NewSet("b", "c", "d"), want: NewSet("b", "c")},
+		{name: "disjoint", x: NewSet("a"), y: NewSet("b"), want: NewSet()},
+		{name: "θ and finite", x: thetaSet(), y: NewSet("a", "b"), want: NewSet("a", "b")},
+		{name: "finite and θ", x: NewSet("a", "b"), y: thetaSet(), want: NewSet("a", "b")},
+		{name: "θ and θ", x: thetaSet(), y: thetaSet(), want: thetaSet()},
+		{name: "large", x: NewSet(keywords("apple", 2000)...), y: NewSet(keywords("apple", 10)...), want: NewSet(keywords("apple", 10)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IntersectSet(tt.x, tt.y)
+			if !got.Equal(tt.want) || got.minimumLen != tt.want.minimumLen {
+				t.Errorf("IntersectSet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDifferenceSet(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y Set
+		want Set
+	}{
+		{name: "empty", x: NewSet(), y: NewSet("a"), want: NewSet()},
+		{name: "remove", x: NewSet("a", "bb", "c"), y: NewSet("a", "c", "d"), want: NewSet("bb")},
+		{name: "nothing to remove", x: NewSet("a", "b"), y: NewSet("c"), want: NewSet("a", "b")},
+		{name: "θ minus finite", x: thetaSet(), y: NewSet("a"), want: thetaSet()},
+		{name: "finite minus θ", x: NewSet("a"), y: thetaSet(), want: NewSet()},
+		{name: "θ minus θ", x: thetaSet(), y: thetaSet(), want: NewSet()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DifferenceSet(tt.x, tt.y)
+			if !got.Equal(tt.want) || got.minimumLen != tt.want.minimumLen {
+				t.Errorf("DifferenceSet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet_Equal(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y Set
+		want bool
+	}{
+		{name: "empty", x: NewSet(), y: NewSet(), want: true},
+		{name: "same", x: NewSet("a", "b"), y: NewSet("b", "a"), want: true},
+		{name: "different", x: NewSet("a", "b"), y: NewSet("a", "c"), want: false},
+		{name: "different size", x: NewSet("a"), y: NewSet("a", "b"), want: false},
+		{name: "θ", x: thetaSet(), y: thetaSet(), want: true},
+		{name: "θ and empty", x: thetaSet(), y: NewSet(), want: false},
+		{name: "trie and slice", x: NewSet(keywords("apple", 1001)...), y: UnionSet(NewSet(keywords("apple", 1001)...), NewSet()), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.x.Equal(tt.y); got != tt.want {
+				t.Errorf("Equal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet_IsSubsetOf(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y Set
+		want bool
+	}{
+		{name: "empty", x: NewSet(), y: NewSet("a"), want: true},
+		{name: "subset", x: NewSet("a", "c"), y: NewSet("a", "b", "c"), want: true},
+		{name: "not subset", x: NewSet("a", "d"), y: NewSet("a", "b", "c"), want: false},
+		{name: "same", x: NewSet("a"), y: NewSet("a"), want: true},
+		{name: "subset of θ", x: NewSet("a"), y: thetaSet(), want: true},
+		{name: "θ of finite", x: thetaSet(), y: NewSet("a"), want: false},
+		{name: "θ of θ", x: thetaSet(), y: thetaSet(), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.x.IsSubsetOf(tt.y); got != tt.want {
+				t.Errorf("IsSubsetOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet_Contains(t *testing.T) {
+	large := NewSet(keywords("apple", 2000)...)
+	tests := []struct {
+		name string
+		set  Set
+		item string
+		want bool
+	}{
+		{name: "empty", set: NewSet(), item: "a", want: false},
+		{name: "found", set: NewSet("a", "b"), item: "b", want: true},
+		{name: "not found", set: NewSet("a", "b"), item: "c", want: false},
+		{name: "empty item", set: NewSet("", "b"), item: "", want: true},
+		{name: "θ", set: thetaSet(), item: "a", want: true},
+		{name: "trie found", set: large, item: keywords("apple", 2000)[1234], want: true},
+		{name: "trie prefix", set: large, item: "apple", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.set.Contains(tt.item); got != tt.want {
+				t.Errorf("Contains(%q) = %v, want %v", tt.item, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet_FirstMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		set       Set
+		text      string
+		wantItem  string
+		wantIndex int
+	}{
+		{name: "empty set", set: NewSet(), text: "abc", wantItem: "", wantIndex: -1},
+		{name: "θ", set: thetaSet(), text: "abc", wantItem: "", wantIndex: 0},
+		{name: "no match", set: NewSet("x", "yz"), text: "abc", wantItem: "", wantIndex: -1},
+		{name: "leftmost", set: NewSet("cd", "bcd", "e"), text: "abcde", wantItem: "bcd", wantIndex: 1},
+		{name: "longest at the index", set: NewSet("b", "bc", "bcd", "cdefg"), text: "abcdefg", wantItem: "bcd", wantIndex: 1},
+		{name: "empty item", set: NewSet("", "ab"), text: "abc", wantItem: "ab", wantIndex: 0},
+		{name: "empty item only", set: NewSet("", "bc"), text: "abc", wantItem: "", wantIndex: 0},
+		{name: "multibyte", set: NewSet("寿司", "すし"), text: "回転すし寿司", wantItem: "すし", wantIndex: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, index := tt.set.FirstMatch([]byte(tt.text))
+			if item != tt.wantItem || index != tt.wantIndex {
+				t.Errorf("FirstMatch() = (%q, %d), want (%q, %d)", item, index, tt.wantItem, tt.wantIndex)
+			}
+			if got, want := tt.set.MatchesText([]byte(tt.text)), tt.wantIndex >= 0; got != want {
+				t.Errorf("MatchesText() = %v, want %v", got, want)
+			}
+			// A matcher gives the same results for every text.
+			m := tt.set.Matcher()
+			for i := 0; i < 2; i++ {
+				if item, index := m.FirstMatch([]byte(tt.text)); item != tt.wantItem || index != tt.wantIndex {
+					t.Errorf("Matcher().FirstMatch() = (%q, %d), want (%q, %d)", item, index, tt.wantItem, tt.wantIndex)
+				}
+			}
+		})
+	}
+}
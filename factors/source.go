@@ -0,0 +1,207 @@
+package factors
+
+import (
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// Span represents a byte range [Start, End) of a pattern.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Source returns the spans of the pattern which contribute a given item of the set,
+// in the order of the positions without overlaps.
+// It returns nil if the item is not in the set or the set has no sources,
+// i.e. the set is not a result of Analyzer.FactorWithSource.
+func (s Set) Source(item string) []Span {
+	if s.src == nil || !s.Contains(item) {
+		return nil
+	}
+	return s.src[item]
+}
+
+// FactorWithSource parses (with syntax.Perl flags) and analyzes a given pattern,
+// and returns necessary factors whose items carry the spans of the pattern which contribute them.
+// The factors are the ones of Factor, which analyzes the simplified parse tree, and the sources come from
+// the analysis of the parse tree keeping the positions of the sub expressions as written.
+// An item which only the simplification yields, e.g. by factoring out the common prefix of alternatives,
+// comes from the spans of the items containing it, or from the whole pattern if there are no such items.
+func (a Analyzer) FactorWithSource(pattern string) (Factor, error) {
+	re, spans, err := parseWithSpans(pattern)
+	if err != nil {
+		return Factor{}, err
+	}
+	simplified, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return Factor{}, err
+	}
+	// The positional analysis does not reuse factors of structurally identical sub expressions, because their sources differ.
+	m := &memo{spans: spans}
+	src := m.analyze(re, false).Factor
+	ret := a.Factor(simplified)
+	whole := []Span{{Start: 0, End: len(pattern)}}
+	ret.Exact.src = sourceOf(ret.Exact, src.Exact, whole)
+	ret.Prefix.src = sourceOf(ret.Prefix, src.Prefix, whole)
+	ret.Suffix.src = sourceOf(ret.Suffix, src.Suffix, whole)
+	ret.Fragment.src = sourceOf(ret.Fragment, src.Fragment, whole)
+	return ret, nil
+}
+
+// sourceOf returns the sources of the items of s which come from the set from of the positional analysis.
+func sourceOf(s, from Set, whole []Span) map[string][]Span {
+	if s.infinite || s.size() == 0 {
+		return nil
+	}
+	items := s.list()
+	ret := make(map[string][]Span, len(items))
+	var others stringSet
+	for _, v := range items {
+		if spans := from.Source(v); spans != nil {
+			ret[v] = spans
+			continue
+		}
+		if others == nil {
+			others = from.list()
+		}
+		var spans []Span
+		for _, w := range others {
+			if strings.Contains(w, v) {
+				spans = mergeSpans(spans, from.src[w])
+			}
+		}
+		if spans == nil {
+			spans = whole
+		}
+		ret[v] = spans
+	}
+	return ret
+}
+
+// attachSource sets the sources of the items of a node of a given regexp.
+// The items of a leaf come from the span of the leaf, and the items of the other nodes
+// without sources, e.g. added by the analysis of the node, come from the span of the node.
+func (m *memo) attachSource(re *syntax.Regexp, f *Factor) {
+	span, ok := m.spans[re]
+	if !ok {
+		return
+	}
+	leaf := len(re.Sub) == 0
+	for _, s := range []*Set{&f.Exact, &f.Prefix, &f.Suffix, &f.Fragment} {
+		if s.infinite {
+			continue
+		}
+		if !leaf && hasAllSources(*s) {
+			continue
+		}
+		items := s.list()
+		src := make(map[string][]Span, len(items))
+		for _, v := range items {
+			if spans, ok := s.src[v]; ok && !leaf {
+				src[v] = spans
+				continue
+			}
+			src[v] = []Span{span}
+		}
+		s.src = src
+	}
+}
+
+// hasAllSources returns true if every item of the set has its sources.
+func hasAllSources(s Set) bool {
+	if s.src == nil {
+		return s.size() == 0
+	}
+	for _, v := range s.list() {
+		if _, ok := s.src[v]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// pruneSource drops the sources of the items which the set no longer has, e.g. dropped as redundant.
+// The sources may be shared with other sets, so it makes a new map.
+func (s *Set) pruneSource() {
+	if s.src == nil {
+		return
+	}
+	items := s.list()
+	src := make(map[string][]Span, len(items))
+	for _, v := range items {
+		if spans, ok := s.src[v]; ok {
+			src[v] = spans
+		}
+	}
+	s.src = src
+}
+
+// mergeSource returns the sources of the items of ret which are the union of the sources of the items in the sets.
+// It materializes the items of a trie only if the sets have sources.
+func mergeSource(ret Set, sets ...Set) map[string][]Span {
+	var found bool
+	for _, s := range sets {
+		found = found || s.src != nil
+	}
+	if !found || ret.size() == 0 {
+		return nil
+	}
+	items := ret.list()
+	src := make(map[string][]Span, len(items))
+	for _, v := range items {
+		var spans []Span
+		for _, s := range sets {
+			spans = mergeSpans(spans, s.src[v])
+		}
+		if spans != nil {
+			src[v] = spans
+		}
+	}
+	return src
+}
+
+// crossSource returns the sources of the concatenations of the items of x and y,
+// i.e. the union of the sources of the concatenated items. It materializes the items only if x or y has sources.
+func crossSource(x, y Set) map[string][]Span {
+	if x.src == nil && y.src == nil {
+		return nil
+	}
+	xs, ys := x.list(), y.list()
+	ret := make(map[string][]Span, len(xs)*len(ys))
+	for _, v0 := range xs {
+		for _, v1 := range ys {
+			item := v0 + v1
+			ret[item] = mergeSpans(ret[item], mergeSpans(x.src[v0], y.src[v1]))
+		}
+	}
+	return ret
+}
+
+// mergeSpans returns the sorted union of the spans, adjacent or overlapped spans are joined.
+func mergeSpans(x, y []Span) []Span {
+	if len(y) == 0 {
+		return x
+	}
+	if len(x) == 0 {
+		return y
+	}
+	spans := make([]Span, 0, len(x)+len(y))
+	spans = append(spans, x...)
+	spans = append(spans, y...)
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].Start < spans[j].Start
+	})
+	ret := spans[:1]
+	for _, v := range spans[1:] {
+		if last := &ret[len(ret)-1]; v.Start <= last.End {
+			if v.End > last.End {
+				last.End = v.End
+			}
+			continue
+		}
+		ret = append(ret, v)
+	}
+	return ret
+}
@@ -0,0 +1,137 @@
+package factors
+
+import (
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+)
+
+var sourcePatterns = []string{
+	`((GA|AAA)*)(TA|AG)`,
+	`abc.*def`,
+	`(?i)ab(?-i:cd)`,
+	`[a-c]x\d{2}`,
+	`foo(bar|baz)+qux`,
+	`\Qa.b\E|x{2,3}`,
+	`a|b|c`,
+	`(?:xy|ab)(?:c|)d*`,
+	`(?:ab|abc)d*`,
+	`(?P<user>[a-z]+)@example\.com`,
+	`^GET /api/v[12]/users\b`,
+	`(?m)^ERROR: (.*?) at line \d+$`,
+	`[]a]b[^]x]`,
+	`[[:alpha:]]+\x41\x{42}\101\pL\p{Greek}`,
+	`a{,2}b{2}`,
+	`(?s:.)(?i)x|y`,
+	`(?:ab|cd)?ef(?U)g+?`,
+}
+
+func TestAnalyzer_FactorWithSource(t *testing.T) {
+	tests := []struct {
+		pattern string
+		set     func(f Factor) Set
+		item    string
+		want    []Span
+	}{
+		{pattern: `((GA|AAA)*)(TA|AG)`, set: func(f Factor) Set { return f.Fragment }, item: "AG", want: []Span{{Start: 15, End: 17}}},
+		{pattern: `((GA|AAA)*)(TA|AG)`, set: func(f Factor) Set { return f.Suffix }, item: "TA", want: []Span{{Start: 12, End: 14}}},
+		{pattern: `abc.*def`, set: func(f Factor) Set { return f.Prefix }, item: "abc", want: []Span{{Start: 0, End: 3}}},
+		{pattern: `(?i)ab(?-i:cd)`, set: func(f Factor) Set { return f.Exact }, item: "aBcd", want: []Span{{Start: 4, End: 6}, {Start: 11, End: 13}}},
+		{pattern: `foo(bar|baz)+qux`, set: func(f Factor) Set { return f.Fragment }, item: "bazqux", want: []Span{{Start: 8, End: 11}, {Start: 13, End: 16}}},
+		{pattern: `foo(bar|baz)+qux`, set: func(f Factor) Set { return f.Prefix }, item: "foobar", want: []Span{{Start: 0, End: 3}, {Start: 4, End: 7}}},
+		{pattern: `x.y|z`, set: func(f Factor) Set { return f.Fragment }, item: "z", want: []Span{{Start: 4, End: 5}}},
+		{pattern: `x.y|z`, set: func(f Factor) Set { return f.Fragment }, item: "w", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.item, func(t *testing.T) {
+			f, err := NewAnalyzer().FactorWithSource(tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+			if got := tt.set(f).Source(tt.item); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Source(%q) = %v, want %v", tt.item, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_FactorWithSource_factor(t *testing.T) {
+	for _, pattern := range sourcePatterns {
+		t.Run(pattern, func(t *testing.T) {
+			got, err := NewAnalyzer().FactorWithSource(pattern)
+			if err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+			re, err := syntax.Parse(pattern, syntax.Perl)
+			if err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+			if want := NewAnalyzer().Factor(re); got.String() != want.String() {
+				t.Errorf("FactorWithSource() = %v, want %v", got, want)
+			}
+			for _, s := range []Set{got.Exact, got.Prefix, got.Suffix, got.Fragment} {
+				for _, v := range s.Items() {
+					if len(s.Source(v)) == 0 {
+						t.Errorf("no source of %q in %v", v, s)
+					}
+				}
+				for v := range s.src {
+					if !s.Contains(v) {
+						t.Errorf("stale source of %q in %v", v, s)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzer_FactorWithSource_trie(t *testing.T) {
+	f, err := NewAnalyzer().FactorWithSource(`[a-j][a-j][a-j][a-j]x.*y`)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	if f.Prefix.trie == nil {
+		t.Fatalf("the prefix set of %d items is not in a trie", f.Prefix.Len())
+	}
+	want := []Span{{Start: 0, End: 21}}
+	for _, v := range f.Prefix.Items() {
+		if got := f.Prefix.Source(v); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Source(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func Test_parseWithSpans(t *testing.T) {
+	texts := []string{"", "GAAAATA", "abcxdef", "aBcd", "ABCD", "bx42", "foobazbarqux", "a.b", "xxx", "c", "alice@example.com",
+		"GET /api/v2/users", "GET /api/v3/users", "x\nERROR: boom at line 12\ny", "]b^", "ΩAB\x41Bπα", "aabb", "\nX", "y", "efgg", "cdef"}
+	for _, pattern := range sourcePatterns {
+		t.Run(pattern, func(t *testing.T) {
+			re, spans, err := parseWithSpans(pattern)
+			if err != nil {
+				t.Fatalf("unexpected error, %v", err)
+			}
+			if got, want := spans[re], (Span{Start: 0, End: len(pattern)}); got != want {
+				t.Errorf("span of the root = %v, want %v", got, want)
+			}
+			want := regexp.MustCompile(pattern)
+			got := regexp.MustCompile(re.String())
+			if got.NumSubexp() != want.NumSubexp() || !reflect.DeepEqual(got.SubexpNames(), want.SubexpNames()) {
+				t.Errorf("sub expressions = %v, want %v", got.SubexpNames(), want.SubexpNames())
+			}
+			for _, text := range texts {
+				if g, w := got.FindStringSubmatchIndex(text), want.FindStringSubmatchIndex(text); !reflect.DeepEqual(g, w) {
+					t.Errorf("%v: FindStringSubmatchIndex(%q) = %v, want %v", re, text, g, w)
+				}
+			}
+		})
+	}
+}
+
+func Test_parseWithSpans_error(t *testing.T) {
+	for _, pattern := range []string{`a(b`, `a**`, `[a`, `(?z)`} {
+		if _, _, err := parseWithSpans(pattern); err == nil {
+			t.Errorf("parseWithSpans(%q) expected error", pattern)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package factors
+
+import (
+	"regexp/syntax"
+	"unicode"
+)
+
+// Token represents a token of a text.
+type Token struct {
+	// Text is the token in the index, it may be normalized, e.g. lower cased.
+	Text string
+	// Start and End are the byte range [Start, End) of the token in the text.
+	Start, End int
+}
+
+// Tokenizer splits a text into tokens as a word index does.
+// The boundaries of the tokens must be determined by the runes around them, e.g. separators,
+// so that a boundary in a literal is also a boundary in any text containing the literal.
+type Tokenizer interface {
+	Tokenize(text string) []Token
+}
+
+// TokenizerFunc is an adapter to use a function as a tokenizer.
+type TokenizerFunc func(text string) []Token
+
+// Tokenize calls f(text).
+func (f TokenizerFunc) Tokenize(text string) []Token {
+	return f(text)
+}
+
+// DefaultTokenizer splits a text on non-word runes, i.e. runes except letters, digits and '_'.
+var DefaultTokenizer Tokenizer = TokenizerFunc(splitWords)
+
+func splitWords(text string) []Token {
+	var ret []Token
+	start := -1
+	for i, r := range text {
+		word := r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			ret = append(ret, Token{Text: text[start:i], Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		ret = append(ret, Token{Text: text[start:], Start: start, End: len(text)})
+	}
+	return ret
+}
+
+// WithTokenizer sets a tokenizer of the token analysis, it defaults to DefaultTokenizer.
+func WithTokenizer(t Tokenizer) Option {
+	return func(a *Analyzer) {
+		a.tokenizer = t
+	}
+}
+
+// Tokens represents the requirements of tokens derived from a literal.
+// A token in the middle of the literal is a whole token of the text, and a token at the edges of the literal
+// is a part of a token of the text, because the text may continue the token beyond the literal.
+type Tokens struct {
+	// Whole is the tokens the text has.
+	Whole []string
+	// Prefix is the prefixes of tokens of the text, i.e. the tokens at the end of the literal.
+	Prefix []string
+	// Suffix is the suffixes of tokens of the text, i.e. the tokens at the start of the literal.
+	Suffix []string
+}
+
+// TokenFactor represents the token requirements of a regexp.
+// The requirements of each set are alternatives, i.e. a text satisfies the requirements derived from one of the items.
+// They are nil if the set has no requirement, e.g. the set is θ or an item has no token
+// which is not in the middle of a word.
+type TokenFactor struct {
+	Exact, Prefix, Suffix, Fragment []Tokens
+	// Query is the query for a word index which requires all the sets,
+	// a literal "tok" is a whole token, "tok*" is a prefix of a token and "*tok" is a suffix of a token.
+	Query *Query
+}
+
+// Tokens analyzes a given regexp and returns the requirements of tokens of the texts which have a match.
+func (a Analyzer) Tokens(re *syntax.Regexp) TokenFactor {
+	t := a.tokenizer
+	if t == nil {
+		t = DefaultTokenizer
+	}
+	f := a.Factor(re)
+	ret := TokenFactor{
+		Exact:    setTokens(t, f.Exact),
+		Prefix:   setTokens(t, f.Prefix),
+		Suffix:   setTokens(t, f.Suffix),
+		Fragment: setTokens(t, f.Fragment),
+	}
+	ret.Query = allQuery
+	for _, alts := range [][]Tokens{ret.Exact, ret.Prefix, ret.Suffix, ret.Fragment} {
+		ret.Query = ret.Query.and(tokensQuery(alts))
+	}
+	return ret
+}
+
+// setTokens returns the token requirements of the items of the set, nil if some item has no requirement.
+func setTokens(t Tokenizer, s Set) []Tokens {
+	if s.infinite {
+		return nil
+	}
+	items := s.list()
+	ret := make([]Tokens, 0, len(items))
+	for _, v := range items {
+		tokens, ok := literalTokens(t, v)
+		if !ok {
+			return nil
+		}
+		ret = append(ret, tokens)
+	}
+	return ret
+}
+
+// literalTokens returns the token requirements of a literal, false if it has no requirement.
+func literalTokens(t Tokenizer, literal string) (Tokens, bool) {
+	var ret Tokens
+	for _, v := range t.Tokenize(literal) {
+		// A token touching an edge may continue beyond the literal unless the literal ends with a boundary,
+		// i.e. the rune at the edge is not a part of the token.
+		atStart, atEnd := v.Start == 0, v.End == len(literal)
+		switch {
+		case atStart && atEnd:
+			// in the middle of a word.
+		case atStart:
+			ret.Suffix = append(ret.Suffix, v.Text)
+		case atEnd:
+			ret.Prefix = append(ret.Prefix, v.Text)
+		default:
+			ret.Whole = append(ret.Whole, v.Text)
+		}
+	}
+	if len(ret.Whole)+len(ret.Prefix)+len(ret.Suffix) == 0 {
+		return ret, false
+	}
+	ret.Whole = cleanStrings(ret.Whole, false)
+	ret.Prefix = cleanStrings(ret.Prefix, false)
+	ret.Suffix = cleanStrings(ret.Suffix, false)
+	return ret, true
+}
+
+// tokensQuery returns the query which requires one of the alternatives.
+func tokensQuery(alts []Tokens) *Query {
+	if alts == nil {
+		return allQuery
+	}
+	q := noneQuery
+	for _, v := range alts {
+		var literals []string
+		literals = append(literals, v.Whole...)
+		for _, p := range v.Prefix {
+			literals = append(literals, p+"*")
+		}
+		for _, s := range v.Suffix {
+			literals = append(literals, "*"+s)
+		}
+		q = q.or(&Query{Op: QAnd, Literal: cleanStrings(literals, false)})
+	}
+	return q
+}
@@ -0,0 +1,73 @@
+package factors
+
+import (
+	"reflect"
+	"regexp/syntax"
+	"strings"
+	"testing"
+)
+
+func Test_literalTokens(t *testing.T) {
+	tests := []struct {
+		literal string
+		want    Tokens
+		ok      bool
+	}{
+		{literal: "", ok: false},
+		{literal: "oob", ok: false},
+		{literal: "foo bar", want: Tokens{Prefix: []string{"bar"}, Suffix: []string{"foo"}}, ok: true},
+		{literal: "foo bar baz", want: Tokens{Whole: []string{"bar"}, Prefix: []string{"baz"}, Suffix: []string{"foo"}}, ok: true},
+		{literal: " foo-bar.", want: Tokens{Whole: []string{"bar", "foo"}}, ok: true},
+		{literal: "寿司 ラーメン", want: Tokens{Prefix: []string{"ラーメン"}, Suffix: []string{"寿司"}}, ok: true},
+	}
+	for _, tt := range tests {
+		got, ok := literalTokens(DefaultTokenizer, tt.literal)
+		if ok != tt.ok || (ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("literalTokens(%q) = %+v, %v, want %+v, %v", tt.literal, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAnalyzer_Tokens(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: `hello world`, want: `"*hello" "world*"`},
+		{pattern: `say (hello|goodbye) world`, want: `"*say" "world*" ("goodbye"|"hello")`},
+		{pattern: `error: .* not found`, want: `"*error" "found*" "not"`},
+		{pattern: `abc[0-9]+`, want: `+`},
+		{pattern: `a\b`, want: `+`},
+	}
+	for _, tt := range tests {
+		re, err := syntax.Parse(tt.pattern, syntax.Perl)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		if got := NewAnalyzer().Tokens(re).Query.String(); got != tt.want {
+			t.Errorf("Tokens(%q).Query = %s, want %s", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzer_Tokens_Tokenizer(t *testing.T) {
+	lower := TokenizerFunc(func(text string) []Token {
+		ret := DefaultTokenizer.Tokenize(text)
+		for i := range ret {
+			ret[i].Text = strings.ToLower(ret[i].Text)
+		}
+		return ret
+	})
+	re, err := syntax.Parse(`Hello, World`, syntax.Perl)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+	got := NewAnalyzer(WithTokenizer(lower)).Tokens(re)
+	want := []Tokens{{Prefix: []string{"world"}, Suffix: []string{"hello"}}}
+	if !reflect.DeepEqual(got.Exact, want) {
+		t.Errorf("Tokens().Exact = %+v, want %+v", got.Exact, want)
+	}
+	if got, want := got.Query.String(), `"*hello" "world*"`; got != want {
+		t.Errorf("Tokens().Query = %s, want %s", got, want)
+	}
+}
@@ -15,6 +15,8 @@ type Node struct {
 	Regexp   *syntax.Regexp
 	Child    []*Node
 	Internal bool
+	// Choices is the choices of the sets of the factor, it is recorded only in the parse tree.
+	Choices []Choice
 }
 
 // String returns string representation of a node.
@@ -0,0 +1,328 @@
+package factors
+
+import (
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// trieNode is a node of an acyclic word graph (a trie sharing its sub graphs).
+// A node is never modified after it is built, so sub graphs are shared among sets;
+// e.g. the cross set grafts the graph of the right hand side on the terminal nodes of the left hand side
+// instead of concatenating every pair of strings.
+type trieNode struct {
+	terminal bool
+	edges    []trieEdge // sorted by label.
+}
+
+type trieEdge struct {
+	label byte
+	to    *trieNode
+}
+
+var trieLeaf = &trieNode{terminal: true}
+
+// newTrie builds a trie of given items.
+func newTrie(items []string) *trieNode {
+	sorted := append([]string(nil), items...)
+	sort.Strings(sorted)
+	return buildTrie(sorted, 0)
+}
+
+// buildTrie builds a trie of the sorted items from the depth.
+func buildTrie(items []string, depth int) *trieNode {
+	ret := &trieNode{}
+	for len(items) > 0 && len(items[0]) == depth {
+		ret.terminal = true
+		items = items[1:]
+	}
+	for len(items) > 0 {
+		label := items[0][depth]
+		i := 1
+		for i < len(items) && items[i][depth] == label {
+			i++
+		}
+		ret.edges = append(ret.edges, trieEdge{label: label, to: buildTrie(items[:i], depth+1)})
+		items = items[i:]
+	}
+	if ret.terminal && len(ret.edges) == 0 {
+		return trieLeaf
+	}
+	return ret
+}
+
+type triePair struct {
+	x, y *trieNode
+}
+
+// trieUnion returns a trie of the union of x and y.
+func trieUnion(x, y *trieNode) *trieNode {
+	return trieUnionMemo(x, y, map[triePair]*trieNode{})
+}
+
+func trieUnionMemo(x, y *trieNode, memo map[triePair]*trieNode) *trieNode {
+	if x == nil || x == y {
+		return y
+	}
+	if y == nil {
+		return x
+	}
+	key := triePair{x: x, y: y}
+	if ret, ok := memo[key]; ok {
+		return ret
+	}
+	ret := &trieNode{
+		terminal: x.terminal || y.terminal,
+		edges:    make([]trieEdge, 0, len(x.edges)+len(y.edges)),
+	}
+	i, j := 0, 0
+	for i < len(x.edges) || j < len(y.edges) {
+		switch {
+		case j == len(y.edges) || (i < len(x.edges) && x.edges[i].label < y.edges[j].label):
+			ret.edges = append(ret.edges, x.edges[i])
+			i++
+		case i == len(x.edges) || x.edges[i].label > y.edges[j].label:
+			ret.edges = append(ret.edges, y.edges[j])
+			j++
+		default:
+			ret.edges = append(ret.edges, trieEdge{
+				label: x.edges[i].label,
+				to:    trieUnionMemo(x.edges[i].to, y.edges[j].to, memo),
+			})
+			i++
+			j++
+		}
+	}
+	memo[key] = ret
+	return ret
+}
+
+// trieCross returns a trie of the cross set of x and y. The graph of y is shared.
+func trieCross(x, y *trieNode) *trieNode {
+	memo := map[*trieNode]*trieNode{}
+	union := map[triePair]*trieNode{}
+	var graft func(n *trieNode) *trieNode
+	graft = func(n *trieNode) *trieNode {
+		if ret, ok := memo[n]; ok {
+			return ret
+		}
+		var ret *trieNode
+		if len(n.edges) != 0 {
+			ret = &trieNode{edges: make([]trieEdge, len(n.edges))}
+			for i, e := range n.edges {
+				ret.edges[i] = trieEdge{label: e.label, to: graft(e.to)}
+			}
+		}
+		if n.terminal {
+			ret = trieUnionMemo(ret, y, union)
+		}
+		memo[n] = ret
+		return ret
+	}
+	return graft(x)
+}
+
+// trieDropRedundantPrefix returns a trie without items which have a prefix in the trie.
+func trieDropRedundantPrefix(n *trieNode) *trieNode {
+	memo := map[*trieNode]*trieNode{}
+	var drop func(n *trieNode) *trieNode
+	drop = func(n *trieNode) *trieNode {
+		if n.terminal {
+			return trieLeaf
+		}
+		if ret, ok := memo[n]; ok {
+			return ret
+		}
+		ret := &trieNode{edges: make([]trieEdge, len(n.edges))}
+		for i, e := range n.edges {
+			ret.edges[i] = trieEdge{label: e.label, to: drop(e.to)}
+		}
+		memo[n] = ret
+		return ret
+	}
+	return drop(n)
+}
+
+// trieDropRedundantSuffix returns a trie without items which have a proper suffix in the trie.
+func trieDropRedundantSuffix(n *trieNode) *trieNode {
+	return trieFilterBySuffixes(n, func(n *trieNode, suffixes []*trieNode) (keep, descend bool) {
+		return n.terminal && !anyTerminal(suffixes), true
+	})
+}
+
+// trieDropRedundantFragment returns a trie without items which contain another item in the trie.
+func trieDropRedundantFragment(n *trieNode) *trieNode {
+	return trieFilterBySuffixes(n, func(n *trieNode, suffixes []*trieNode) (keep, descend bool) {
+		if anyTerminal(suffixes) {
+			// The items from here contain the suffix.
+			return false, false
+		}
+		// The longer items contain this one.
+		return n.terminal, !n.terminal
+	})
+}
+
+func anyTerminal(nodes []*trieNode) bool {
+	for _, v := range nodes {
+		if v.terminal {
+			return true
+		}
+	}
+	return false
+}
+
+// trieFilterBySuffixes returns a trie of the items which visit keeps, without materializing them.
+// It walks the graph with the nodes which the proper suffixes of the walked string reach from the root,
+// and visit reports whether to keep the walked string as an item and whether to walk the longer strings.
+// The walks are shared among the paths which reach the same node with the same suffix nodes.
+func trieFilterBySuffixes(root *trieNode, visit func(n *trieNode, suffixes []*trieNode) (keep, descend bool)) *trieNode {
+	ids := map[*trieNode]int{}
+	id := func(n *trieNode) int {
+		if ret, ok := ids[n]; ok {
+			return ret
+		}
+		ids[n] = len(ids)
+		return ids[n]
+	}
+	memo := map[string]*trieNode{}
+	var walk func(n *trieNode, suffixes []*trieNode) *trieNode
+	walk = func(n *trieNode, suffixes []*trieNode) *trieNode {
+		key := strconv.AppendInt(nil, int64(id(n)), 36)
+		for _, v := range suffixes {
+			key = append(key, ' ')
+			key = strconv.AppendInt(key, int64(id(v)), 36)
+		}
+		if ret, ok := memo[string(key)]; ok {
+			return ret
+		}
+		keep, descend := visit(n, suffixes)
+		ret := &trieNode{terminal: keep}
+		for i := 0; descend && i < len(n.edges); i++ {
+			e := n.edges[i]
+			// The empty suffix of the longer string reaches the root.
+			next := []*trieNode{root}
+			for _, v := range suffixes {
+				if to := trieNext(v, e.label); to != nil {
+					next = append(next, to)
+				}
+			}
+			sort.Slice(next, func(i, j int) bool { return id(next[i]) < id(next[j]) })
+			uniq := next[:1]
+			for _, v := range next[1:] {
+				if v != uniq[len(uniq)-1] {
+					uniq = append(uniq, v)
+				}
+			}
+			if to := walk(e.to, uniq); to != nil {
+				ret.edges = append(ret.edges, trieEdge{label: e.label, to: to})
+			}
+		}
+		switch {
+		case len(ret.edges) != 0:
+		case keep:
+			ret = trieLeaf
+		default:
+			ret = nil
+		}
+		memo[string(key)] = ret
+		return ret
+	}
+	return walk(root, nil)
+}
+
+// trieLen returns the number of items in the trie.
+func trieLen(n *trieNode) int {
+	memo := map[*trieNode]int{}
+	var count func(n *trieNode) int
+	count = func(n *trieNode) int {
+		if ret, ok := memo[n]; ok {
+			return ret
+		}
+		var ret int
+		if n.terminal {
+			ret = 1
+		}
+		for _, e := range n.edges {
+			ret += count(e.to)
+		}
+		memo[n] = ret
+		return ret
+	}
+	return count(n)
+}
+
+// trieMinimumLen returns the length of the shortest item in the trie.
+func trieMinimumLen(n *trieNode) int {
+	memo := map[*trieNode]int{}
+	var depth func(n *trieNode) int
+	depth = func(n *trieNode) int {
+		if n.terminal {
+			return 0
+		}
+		if ret, ok := memo[n]; ok {
+			return ret
+		}
+		ret := -1
+		for _, e := range n.edges {
+			if d := depth(e.to) + 1; ret < 0 || d < ret {
+				ret = d
+			}
+		}
+		memo[n] = ret
+		return ret
+	}
+	return depth(n)
+}
+
+// trieItems returns the sorted items of the trie.
+func trieItems(n *trieNode) []string {
+	var ret []string
+	var buf []byte
+	var walk func(n *trieNode)
+	walk = func(n *trieNode) {
+		if n.terminal {
+			ret = append(ret, string(buf))
+		}
+		for _, e := range n.edges {
+			buf = append(buf, e.label)
+			walk(e.to)
+			buf = buf[:len(buf)-1]
+		}
+	}
+	walk(n)
+	return ret
+}
+
+// trieLongestCommonPrefix returns the longest common prefix of the items in the trie.
+func trieLongestCommonPrefix(n *trieNode) string {
+	var buf []byte
+	for !n.terminal && len(n.edges) == 1 {
+		buf = append(buf, n.edges[0].label)
+		n = n.edges[0].to
+	}
+	// Drop an incomplete UTF-8 sequence.
+	for len(buf) > 0 {
+		if r, size := utf8.DecodeLastRune(buf); r != utf8.RuneError || size > 1 {
+			break
+		}
+		buf = buf[:len(buf)-1]
+	}
+	return string(buf)
+}
+
+// trieContains returns true if the trie has the item.
+func trieContains(n *trieNode, item string) bool {
+	for i := 0; i < len(item) && n != nil; i++ {
+		n = trieNext(n, item[i])
+	}
+	return n != nil && n.terminal
+}
+
+// trieNext returns the node which the edge of the label reaches, nil if there is no such edge.
+func trieNext(n *trieNode, label byte) *trieNode {
+	i := sort.Search(len(n.edges), func(i int) bool { return n.edges[i].label >= label })
+	if i == len(n.edges) || n.edges[i].label != label {
+		return nil
+	}
+	return n.edges[i].to
+}
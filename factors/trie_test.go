@@ -0,0 +1,181 @@
+package factors
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func Test_trie(t *testing.T) {
+	x := newTrie([]string{"ab", "a", "abc", "b"})
+	y := newTrie([]string{"", "c"})
+	tests := []struct {
+		name string
+		trie *trieNode
+		want []string
+	}{
+		{
+			name: "items",
+			trie: x,
+			want: []string{"a", "ab", "abc", "b"},
+		},
+		{
+			name: "union",
+			trie: trieUnion(x, newTrie([]string{"abd", "c"})),
+			want: []string{"a", "ab", "abc", "abd", "b", "c"},
+		},
+		{
+			name: "cross",
+			trie: trieCross(x, y),
+			want: []string{"a", "ab", "abc", "abcc", "ac", "b", "bc"},
+		},
+		{
+			name: "drop redundant prefix",
+			trie: trieDropRedundantPrefix(x),
+			want: []string{"a", "b"},
+		},
+		{
+			name: "drop redundant suffix",
+			trie: trieDropRedundantSuffix(x),
+			want: []string{"a", "abc", "b"},
+		},
+		{
+			name: "drop redundant fragment",
+			trie: trieDropRedundantFragment(x),
+			want: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trieItems(tt.trie); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("trieItems() = %v, want %v", got, tt.want)
+			}
+			if got := trieLen(tt.trie); got != len(tt.want) {
+				t.Errorf("trieLen() = %v, want %v", got, len(tt.want))
+			}
+			if got, want := trieMinimumLen(tt.trie), minLenStrings(tt.want); got != want {
+				t.Errorf("trieMinimumLen() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_trieDropRedundant_random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := func() []string {
+		items := make([]string, 1+rnd.Intn(6))
+		for i := range items {
+			b := make([]byte, rnd.Intn(4))
+			for j := range b {
+				b[j] = "abc"[rnd.Intn(3)]
+			}
+			items[i] = string(b)
+		}
+		return items
+	}
+	for n := 0; n < 200; n++ {
+		// The cross sets share the sub graphs.
+		trie := trieCross(newTrie(random()), trieUnion(newTrie(random()), trieCross(newTrie(random()), newTrie(random()))))
+		items := trieItems(trie)
+		suffix := NewSet(items...)
+		suffix.DropRedundantSuffix()
+		if got, want := trieItems(trieDropRedundantSuffix(trie)), suffix.Items(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("trieDropRedundantSuffix(%v) = %v, want %v", items, got, want)
+		}
+		if items[0] == "" {
+			continue
+		}
+		if got, want := trieItems(trieDropRedundantFragment(trie)), dropRedundantFragmentNaive(items); !reflect.DeepEqual(got, want) {
+			t.Fatalf("trieDropRedundantFragment(%v) = %v, want %v", items, got, want)
+		}
+	}
+}
+
+func keywords(prefix string, n int) []string {
+	ret := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		ret = append(ret, fmt.Sprintf("%s%03d", prefix, i))
+	}
+	return ret
+}
+
+func crossStringsNaive(x, y []string) []string {
+	var ret []string
+	for _, s := range x {
+		for _, t := range y {
+			ret = append(ret, s+t)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestSet_Trie(t *testing.T) {
+	x := NewSet(keywords("apple", 60)...)
+	y := NewSet(keywords("applet", 60)...)
+
+	cross := CrossSet(x, y)
+	if cross.trie == nil {
+		t.Fatalf("large cross set should be kept in a trie")
+	}
+	want := crossStringsNaive(x.Items(), y.Items())
+	if got := cross.Items(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CrossSet().Items() = %v..., want %v...", got[:3], want[:3])
+	}
+	if got := cross.Len(); got != len(want) {
+		t.Errorf("Len() = %v, want %v", got, len(want))
+	}
+
+	union := UnionSet(cross, NewSet("apple"))
+	if got := union.Len(); got != len(want)+1 {
+		t.Errorf("UnionSet().Len() = %v, want %v", got, len(want)+1)
+	}
+	if got := union.minimumLen; got != 5 {
+		t.Errorf("UnionSet().minimumLen = %v, want 5", got)
+	}
+	union.DropRedundantPrefix()
+	if got := union.Items(); !reflect.DeepEqual(got, []string{"apple"}) {
+		t.Errorf("DropRedundantPrefix() = %v, want [apple]", got)
+	}
+
+	cross.Add("zzz")
+	if got := cross.Items(); got[len(got)-1] != "zzz" || cross.minimumLen != 3 {
+		t.Errorf("Add() = %v, minimumLen %v", got[len(got)-1], cross.minimumLen)
+	}
+
+	// The 60 items which start with apple001 contain it.
+	fragment := UnionSet(CrossSet(x, y), NewSet("apple001"))
+	fragment.DropRedundantFragment()
+	if fragment.trie == nil || fragment.Len() != len(want)-60+1 || !fragment.Contains("apple001") {
+		t.Errorf("DropRedundantFragment() = %v items, want %v items in a trie", fragment.Len(), len(want)-60+1)
+	}
+
+	cross.DropRedundantSuffix()
+	for _, v := range cross.Items() {
+		if v != "zzz" && !strings.HasPrefix(v, "apple") {
+			t.Errorf("unexpected item %v", v)
+		}
+	}
+}
+
+func Test_trieLongestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		items []string
+		want  string
+	}{
+		{items: []string{"apple", "apply"}, want: "appl"},
+		{items: []string{"app", "apple"}, want: "app"},
+		{items: []string{"あ", "い"}, want: ""},
+		{items: []string{"かあ", "かい"}, want: "か"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := trieLongestCommonPrefix(newTrie(tt.items)); got != tt.want {
+				t.Errorf("trieLongestCommonPrefix() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,398 @@
+// The trigram analysis of this file is derived from index/regexp.go of Google Code Search
+// (https://github.com/google/codesearch), which is distributed under the following notice:
+//
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.codesearch file.
+
+package factors
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+const (
+	trigramMaxExact = 7
+	trigramMaxSet   = 20
+)
+
+// TrigramInfo represents the information of a regexp analyzed in the style of the trigram code search,
+// i.e. the analysis of Google Code Search. cf. https://swtch.com/~rsc/regexp/regexp4.html
+type TrigramInfo struct {
+	// CanEmpty is true if the regexp can match an empty string.
+	CanEmpty bool
+	// Exact is the exact set of strings the regexp matches, θ if unknown.
+	Exact Set
+	// Prefix is the set of prefixes of the matched strings, θ if Exact is known.
+	Prefix Set
+	// Suffix is the set of suffixes of the matched strings, θ if Exact is known.
+	Suffix Set
+	// Match is the trigram query every matched string satisfies.
+	Match *Query
+}
+
+// Factor returns a factor tuple of the trigram information to compare with the results of Analyzer.Factor.
+// The fragment set is θ, because the trigram analysis keeps the fragments in the query only.
+func (i TrigramInfo) Factor() Factor {
+	ret := NewFactorInfinite()
+	if !i.Exact.infinite {
+		ret.Exact = i.Exact
+		ret.Prefix = i.Exact
+		ret.Suffix = i.Exact
+		return ret
+	}
+	ret.Prefix = i.Prefix
+	ret.Suffix = i.Suffix
+	return ret
+}
+
+// Trigram analyzes a given regexp and returns the trigram information.
+func (a Analyzer) Trigram(re *syntax.Regexp) TrigramInfo {
+	info := trigramAnalyze(re)
+	ret := TrigramInfo{
+		CanEmpty: info.canEmpty,
+		Exact:    info.exactSet(),
+		Prefix:   NewSet(info.prefix...),
+		Suffix:   NewSet(info.suffix...),
+		Match:    info.match,
+	}
+	if info.exact != nil {
+		ret.Prefix.SetInfinite()
+		ret.Suffix.SetInfinite()
+	}
+	return ret
+}
+
+// TrigramQuery returns the trigram query every string matched by a given regexp satisfies.
+func (a Analyzer) TrigramQuery(re *syntax.Regexp) *Query {
+	info := trigramAnalyze(re)
+	info.simplify(true)
+	info.addExact()
+	return info.match
+}
+
+type trigramInfo struct {
+	canEmpty bool
+	exact    []string // nil means unknown.
+	prefix   []string
+	suffix   []string
+	match    *Query
+}
+
+func (info trigramInfo) exactSet() Set {
+	if info.exact == nil {
+		return Set{infinite: true}
+	}
+	return NewSet(info.exact...)
+}
+
+func trigramAnyMatch() trigramInfo {
+	return trigramInfo{
+		canEmpty: true,
+		prefix:   []string{""},
+		suffix:   []string{""},
+		match:    allQuery,
+	}
+}
+
+func trigramAnyChar() trigramInfo {
+	return trigramInfo{
+		prefix: []string{""},
+		suffix: []string{""},
+		match:  allQuery,
+	}
+}
+
+func trigramNoMatch() trigramInfo {
+	return trigramInfo{
+		match: noneQuery,
+	}
+}
+
+func trigramEmptyString() trigramInfo {
+	return trigramInfo{
+		canEmpty: true,
+		exact:    []string{""},
+		match:    allQuery,
+	}
+}
+
+//nolint:gocyclo
+func trigramAnalyze(re *syntax.Regexp) trigramInfo {
+	var info trigramInfo
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return trigramNoMatch()
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return trigramEmptyString()
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase == 0 {
+			info.exact = []string{string(re.Rune)}
+			info.match = allQuery
+			break
+		}
+		// fold case
+		switch len(re.Rune) {
+		case 0:
+			return trigramEmptyString()
+		case 1:
+			re1 := &syntax.Regexp{
+				Op: syntax.OpCharClass,
+			}
+			re1.Rune = re1.Rune0[:0]
+			r0 := re.Rune[0]
+			re1.Rune = append(re1.Rune, r0, r0)
+			for r1 := unicode.SimpleFold(r0); r1 != r0; r1 = unicode.SimpleFold(r1) {
+				re1.Rune = append(re1.Rune, r1, r1)
+			}
+			return trigramAnalyze(re1)
+		}
+		re1 := &syntax.Regexp{
+			Op:    syntax.OpLiteral,
+			Flags: syntax.FoldCase,
+		}
+		info = trigramEmptyString()
+		for i := range re.Rune {
+			re1.Rune = re.Rune[i : i+1]
+			info = trigramConcat(info, trigramAnalyze(re1))
+		}
+		return info
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		return trigramAnyChar()
+	case syntax.OpCapture:
+		return trigramAnalyze(re.Sub[0])
+	case syntax.OpConcat:
+		return trigramFold(trigramConcat, re.Sub, trigramEmptyString())
+	case syntax.OpAlternate:
+		return trigramFold(trigramAlternate, re.Sub, trigramNoMatch())
+	case syntax.OpQuest:
+		return trigramAlternate(trigramAnalyze(re.Sub[0]), trigramEmptyString())
+	case syntax.OpStar:
+		return trigramAnyMatch()
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return trigramAnyMatch()
+		}
+		fallthrough
+	case syntax.OpPlus:
+		// x+ matches x, so the exact set of x turns into the prefix and the suffix sets.
+		info = trigramAnalyze(re.Sub[0])
+		if info.exact != nil {
+			info.prefix = info.exact
+			info.suffix = append([]string(nil), info.exact...)
+			info.exact = nil
+		}
+	case syntax.OpCharClass:
+		info.match = allQuery
+		if len(re.Rune) == 0 {
+			return trigramNoMatch()
+		}
+		if len(re.Rune) == 1 {
+			info.exact = []string{string(re.Rune[0])}
+			break
+		}
+		n := 0
+		for i := 0; i < len(re.Rune); i += 2 {
+			n += int(re.Rune[i+1] - re.Rune[i])
+		}
+		if n > charClassLimit {
+			return trigramAnyChar()
+		}
+		for i := 0; i < len(re.Rune); i += 2 {
+			lo, hi := re.Rune[i], re.Rune[i+1]
+			for rr := lo; rr <= hi; rr++ {
+				info.exact = append(info.exact, string(rr))
+			}
+		}
+	default:
+		return trigramAnyMatch()
+	}
+	info.simplify(false)
+	return info
+}
+
+// trigramFold folds the information of the sub expressions by f, or returns zero if there is no sub expression.
+func trigramFold(f func(x, y trigramInfo) trigramInfo, sub []*syntax.Regexp, zero trigramInfo) trigramInfo {
+	if len(sub) == 0 {
+		return zero
+	}
+	info := trigramAnalyze(sub[0])
+	for i := 1; i < len(sub); i++ {
+		info = f(info, trigramAnalyze(sub[i]))
+	}
+	return info
+}
+
+// trigramConcat returns the information for xy.
+func trigramConcat(x, y trigramInfo) trigramInfo {
+	var xy trigramInfo
+	xy.match = x.match.and(y.match)
+	if x.exact != nil && y.exact != nil {
+		xy.exact = crossStrings(x.exact, y.exact, false)
+	} else {
+		if x.exact != nil {
+			xy.prefix = crossStrings(x.exact, y.prefix, false)
+		} else {
+			xy.prefix = x.prefix
+			if x.canEmpty {
+				xy.prefix = unionStrings(xy.prefix, y.prefix)
+			}
+		}
+		if y.exact != nil {
+			xy.suffix = crossStrings(x.suffix, y.exact, true)
+		} else {
+			xy.suffix = y.suffix
+			if y.canEmpty {
+				xy.suffix = cleanStrings(append(append([]string(nil), xy.suffix...), x.suffix...), true)
+			}
+		}
+	}
+	// If all the strings in the cross product of x.suffix and y.prefix are long enough,
+	// one of their trigrams must be present.
+	if x.exact == nil && y.exact == nil &&
+		len(x.suffix) <= trigramMaxSet && len(y.prefix) <= trigramMaxSet &&
+		minLenStrings(x.suffix)+minLenStrings(y.prefix) >= 3 {
+		xy.match = xy.match.andTrigrams(crossStrings(x.suffix, y.prefix, false))
+	}
+	xy.canEmpty = x.canEmpty && y.canEmpty
+	xy.simplify(false)
+	return xy
+}
+
+// trigramAlternate returns the information for x|y.
+func trigramAlternate(x, y trigramInfo) trigramInfo {
+	var xy trigramInfo
+	switch {
+	case x.exact != nil && y.exact != nil:
+		xy.exact = unionStrings(x.exact, y.exact)
+	case x.exact != nil:
+		xy.prefix = unionStrings(x.exact, y.prefix)
+		xy.suffix = cleanStrings(append(append([]string(nil), x.exact...), y.suffix...), true)
+		x.addExact()
+	case y.exact != nil:
+		xy.prefix = unionStrings(x.prefix, y.exact)
+		xy.suffix = cleanStrings(append(append([]string(nil), x.suffix...), y.exact...), true)
+		y.addExact()
+	default:
+		xy.prefix = unionStrings(x.prefix, y.prefix)
+		xy.suffix = cleanStrings(append(append([]string(nil), x.suffix...), y.suffix...), true)
+	}
+	xy.canEmpty = x.canEmpty || y.canEmpty
+	xy.match = x.match.or(y.match)
+	xy.simplify(false)
+	return xy
+}
+
+// addExact adds the trigrams of the exact set to the match query.
+func (info *trigramInfo) addExact() {
+	if info.exact != nil {
+		info.match = info.match.andTrigrams(info.exact)
+	}
+}
+
+// simplify reduces the exact set into the match query, the prefix and the suffix sets when it grows too large.
+func (info *trigramInfo) simplify(force bool) {
+	if info.exact != nil {
+		info.exact = cleanStrings(info.exact, false)
+	}
+	if len(info.exact) > trigramMaxSet ||
+		(minLenStrings(info.exact) >= 3 && force) ||
+		minLenStrings(info.exact) >= trigramMaxExact {
+		info.addExact()
+		for _, s := range info.exact {
+			n := len(s)
+			if n < 3 {
+				info.prefix = append(info.prefix, s)
+				info.suffix = append(info.suffix, s)
+			} else {
+				info.prefix = append(info.prefix, s[:2])
+				info.suffix = append(info.suffix, s[n-2:])
+			}
+		}
+		info.exact = nil
+	}
+	if info.exact == nil {
+		info.prefix = info.simplifySet(info.prefix, false)
+		info.suffix = info.simplifySet(info.suffix, true)
+	}
+}
+
+// simplifySet adds the trigrams of the set to the match query, and shortens the strings of the set.
+func (info *trigramInfo) simplifySet(s []string, isSuffix bool) []string {
+	t := cleanStrings(append([]string(nil), s...), isSuffix)
+	info.match = info.match.andTrigrams(t)
+	for n := 3; n == 3 || len(t) > trigramMaxSet; n-- {
+		w := 0
+		for _, str := range t {
+			if len(str) >= n {
+				if !isSuffix {
+					str = str[:n-1]
+				} else {
+					str = str[len(str)-n+1:]
+				}
+			}
+			if w == 0 || t[w-1] != str {
+				t[w] = str
+				w++
+			}
+		}
+		t = cleanStrings(t[:w], isSuffix)
+	}
+	// If "ab" is a possible prefix, it does not help to know that "abc" is also a possible prefix.
+	has := strings.HasPrefix
+	if isSuffix {
+		has = strings.HasSuffix
+	}
+	w := 0
+	for _, str := range t {
+		if w == 0 || !has(str, t[w-1]) {
+			t[w] = str
+			w++
+		}
+	}
+	return t[:w]
+}
+
+// andTrigrams returns q AND (OR of the trigrams of each string).
+func (q *Query) andTrigrams(t []string) *Query {
+	if minLenStrings(t) < 3 {
+		// If there is a short string, we can't guarantee that any trigrams must be present.
+		return q
+	}
+	or := noneQuery
+	for _, s := range t {
+		var trig []string
+		for i := 0; i+2 < len(s); i++ {
+			trig = append(trig, s[i:i+3])
+		}
+		or = or.or(&Query{Op: QAnd, Literal: cleanStrings(trig, false)})
+	}
+	return q.and(or)
+}
+
+// crossStrings returns the cleaned cross product of x and y.
+func crossStrings(x, y []string, isSuffix bool) []string {
+	ret := make([]string, 0, len(x)*len(y))
+	for _, s := range x {
+		for _, t := range y {
+			ret = append(ret, s+t)
+		}
+	}
+	return cleanStrings(ret, isSuffix)
+}
+
+func minLenStrings(s []string) int {
+	if len(s) == 0 {
+		return 0
+	}
+	ret := len(s[0])
+	for _, v := range s[1:] {
+		if len(v) < ret {
+			ret = len(v)
+		}
+	}
+	return ret
+}
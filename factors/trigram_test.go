@@ -0,0 +1,91 @@
+package factors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzer_TrigramQuery(t *testing.T) {
+	tests := []struct {
+		re   string
+		want string
+	}{
+		{re: `Abcdef`, want: `"Abc" "bcd" "cde" "def"`},
+		{re: `(abc)(def)`, want: `"abc" "bcd" "cde" "def"`},
+		{re: `abc.*(def|ghi)`, want: `"abc" ("def"|"ghi")`},
+		{re: `a+hello`, want: `"ahe" "ell" "hel" "llo"`},
+		{re: `a*hello`, want: `"ell" "hel" "llo"`},
+		{re: `def|abc`, want: `("abc"|"def")`},
+		{re: `[ab][cd][ef]`, want: `("ace"|"acf"|"ade"|"adf"|"bce"|"bcf"|"bde"|"bdf")`},
+		{re: `ab[cd]e`, want: `("abc" "bce")|("abd" "bde")`},
+		{re: `(a|b|c|d)(ef|g|hi|j)`, want: `+`},
+		{re: `(?s).`, want: `+`},
+		{re: `abc+de`, want: `"abc" "cde"`},
+		{re: `(abc|abd)x*`, want: `("abc"|"abd")`},
+		{re: `[^\x00-\x{10FFFF}]`, want: `-`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.re, func(t *testing.T) {
+			if got := NewAnalyzer().TrigramQuery(syntaxRegexp(t, tt.re)).String(); got != tt.want {
+				t.Errorf("TrigramQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_Trigram(t *testing.T) {
+	tests := []struct {
+		re   string
+		want TrigramInfo
+	}{
+		{
+			re: `abc`,
+			want: TrigramInfo{
+				Exact:  NewSet("abc"),
+				Prefix: Set{infinite: true},
+				Suffix: Set{infinite: true},
+				Match:  allQuery,
+			},
+		},
+		{
+			re: `abc+de`,
+			want: TrigramInfo{
+				Exact:  Set{infinite: true},
+				Prefix: NewSet("ab"),
+				Suffix: NewSet("de"),
+				Match:  &Query{Op: QAnd, Literal: []string{"abc", "cde"}},
+			},
+		},
+		{
+			re: `(ab)?`,
+			want: TrigramInfo{
+				CanEmpty: true,
+				Exact:    NewSet("", "ab"),
+				Prefix:   Set{infinite: true},
+				Suffix:   Set{infinite: true},
+				Match:    allQuery,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.re, func(t *testing.T) {
+			if got := NewAnalyzer().Trigram(syntaxRegexp(t, tt.re)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Trigram() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrigramInfo_Factor(t *testing.T) {
+	// Both engines agree on the exact sets of finite patterns.
+	for _, re := range []string{`abc`, `a|b`, `X[abc]Y`, `(AG|GA)(TA|AT)`} {
+		t.Run(re, func(t *testing.T) {
+			a := NewAnalyzer()
+			got := a.Trigram(syntaxRegexp(t, re)).Factor()
+			want := a.Factor(syntaxRegexp(t, re))
+			if !reflect.DeepEqual(got.Exact.Items(), want.Exact.Items()) {
+				t.Errorf("Exact = %v, want %v", got.Exact, want.Exact)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package factors
+
+import (
+	"bytes"
+	"regexp"
+	"regexp/syntax"
+	"unicode/utf8"
+
+	"github.com/ikawaha/factors/factors/ahocorasick"
+)
+
+// WindowMatcher runs a regexp only in the windows around the occurrences of the fragments,
+// so the cost of a search is proportional to the occurrences rather than the size of the text.
+// A match of the regexp contains an occurrence of an item of the fragment set and is no longer than
+// the maximum length of the matches, so it is in the window of the occurrence which extends
+// the occurrence by the maximum length minus the length of the item to both sides.
+// The results are identical to the ones of regexp.Regexp.
+type WindowMatcher struct {
+	re *regexp.Regexp
+	// ac finds the items of the fragment set, nil if it searches the whole text,
+	// e.g. the matches are unbounded, the fragment set is θ or the regexp has empty width assertions,
+	// which depend on the text out of the windows.
+	ac    *ahocorasick.Automaton
+	items []string
+	// maxLen is the maximum length of the matches in bytes.
+	maxLen int
+	// invalid is true if the regexp may match an invalid UTF-8 sequence as U+FFFD,
+	// then it searches the whole text which is not valid UTF-8.
+	invalid bool
+}
+
+// NewWindowMatcher parses (with syntax.Perl flags) a given pattern and returns a window matcher of it.
+func NewWindowMatcher(pattern string) (*WindowMatcher, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sre, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	return NewAnalyzer().windowMatcher(re, sre), nil
+}
+
+func (a Analyzer) windowMatcher(re *regexp.Regexp, sre *syntax.Regexp) *WindowMatcher {
+	ret := &WindowMatcher{re: re}
+	_, hi := lengthRange(sre)
+	f := a.Factor(sre)
+	if hi < 0 || f.Fragment.infinite || f.Fragment.size() == 0 || f.Fragment.Contains("") || hasEmptyWidth(sre) {
+		return ret
+	}
+	ret.items = f.Fragment.Items()
+	ret.ac = ahocorasick.New(ret.items)
+	ret.maxLen = hi
+	ret.invalid = hasRuneError(sre)
+	return ret
+}
+
+// Windows returns the byte ranges [Start, End) of the text where the matches may be,
+// in the order of the positions without overlaps.
+func (m *WindowMatcher) Windows(b []byte) []Span {
+	var ret []Span
+	m.windows(b, func(w Span) bool {
+		ret = append(ret, w)
+		return true
+	})
+	return ret
+}
+
+// Match reports whether the text contains any match of the regexp.
+func (m *WindowMatcher) Match(b []byte) bool {
+	var ret bool
+	m.windows(b, func(w Span) bool {
+		ret = m.re.Match(b[w.Start:w.End])
+		return !ret
+	})
+	return ret
+}
+
+// FindIndex returns the location of the leftmost match of the regexp as regexp.Regexp.FindIndex does.
+func (m *WindowMatcher) FindIndex(b []byte) []int {
+	if ret := m.FindAllIndex(b, 1); ret != nil {
+		return ret[0]
+	}
+	return nil
+}
+
+// FindAllIndex returns the locations of successive matches of the regexp as regexp.Regexp.FindAllIndex does.
+func (m *WindowMatcher) FindAllIndex(b []byte, n int) [][]int {
+	if n == 0 {
+		return nil
+	}
+	var ret [][]int
+	m.windows(b, func(w Span) bool {
+		limit := -1
+		if n > 0 {
+			limit = n - len(ret)
+		}
+		for _, v := range m.re.FindAllIndex(b[w.Start:w.End], limit) {
+			ret = append(ret, []int{v[0] + w.Start, v[1] + w.Start})
+		}
+		return n < 0 || len(ret) < n
+	})
+	return ret
+}
+
+// windows calls fn for the merged windows of the occurrences of the fragments in order. It stops if fn returns false.
+func (m *WindowMatcher) windows(b []byte, fn func(w Span) bool) {
+	if m.ac == nil || (m.invalid && !utf8.Valid(b)) {
+		fn(Span{Start: 0, End: len(b)})
+		return
+	}
+	var cur Span
+	found, next := false, true
+	m.occurrences(b, func(id, end int) bool {
+		// The starts of the windows do not decrease, because the ends of the occurrences do not.
+		w := Span{
+			Start: runeStart(b, maxInt(0, end-m.maxLen)),
+			End:   runeEnd(b, minInt(len(b), end-len(m.items[id])+m.maxLen)),
+		}
+		switch {
+		case !found:
+			cur, found = w, true
+		case w.Start <= cur.End:
+			cur.End = maxInt(cur.End, w.End)
+		default:
+			if next = fn(cur); !next {
+				return false
+			}
+			cur = w
+		}
+		return true
+	})
+	if found && next {
+		fn(cur)
+	}
+}
+
+// occurrences calls fn for the occurrences of the items in the order of the ends as ahocorasick.Automaton.Overlapping does.
+func (m *WindowMatcher) occurrences(b []byte, fn func(id, end int) bool) {
+	if len(m.items) > 1 {
+		m.ac.Overlapping(b, func(o ahocorasick.Match) bool {
+			return fn(o.Pattern, o.End)
+		})
+		return
+	}
+	item := []byte(m.items[0])
+	for i := 0; ; i++ {
+		j := bytes.Index(b[i:], item)
+		if j < 0 {
+			return
+		}
+		i += j
+		if !fn(0, i+len(item)) {
+			return
+		}
+	}
+}
+
+// runeStart returns the start of the rune at i.
+func runeStart(b []byte, i int) int {
+	for i > 0 && i < len(b) && !utf8.RuneStart(b[i]) {
+		i--
+	}
+	return i
+}
+
+// runeEnd returns the end of the rune which ends at or after i.
+func runeEnd(b []byte, i int) int {
+	for i < len(b) && !utf8.RuneStart(b[i]) {
+		i++
+	}
+	return i
+}
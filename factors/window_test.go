@@ -0,0 +1,104 @@
+package factors
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestWindowMatcher_Windows(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		want    []Span
+	}{
+		{pattern: `ab.c`, text: "xxxxabxcxxxxxxxxxxab", want: []Span{{Start: 0, End: 11}, {Start: 13, End: 20}}},
+		{pattern: `ab.c`, text: "xxab abxxx", want: []Span{{Start: 0, End: 10}}},
+		{pattern: `ab.c`, text: "xxxx", want: nil},
+		{pattern: `abc`, text: "xxxxabcxxxxxxxxxxxabc", want: []Span{{Start: 4, End: 7}, {Start: 18, End: 21}}},
+		{pattern: `a.*c`, text: "xxxx", want: []Span{{Start: 0, End: 4}}},
+		{pattern: `\bab`, text: "xxxx", want: []Span{{Start: 0, End: 4}}},
+		{pattern: `.ab`, text: "ああab", want: []Span{{Start: 0, End: 8}}},
+	}
+	for _, tt := range tests {
+		m, err := NewWindowMatcher(tt.pattern)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		if got := m.Windows([]byte(tt.text)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Windows(%q) = %v, want %v", tt.pattern, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWindowMatcher(t *testing.T) {
+	patterns := []string{
+		`abc`,
+		`a(b|c)d`,
+		`(?i)ab.c`,
+		`a[bc]{1,3}d`,
+		`(ab|cd)e?`,
+		`.b.`,
+		`a+b`,
+		`\bab`,
+		`\x{FFFD}b`,
+		`x(a|ab)(c|bcd)`,
+	}
+	r := rand.New(rand.NewSource(1))
+	var texts []string
+	for i := 0; i < 300; i++ {
+		b := make([]byte, r.Intn(40))
+		for j := range b {
+			b[j] = "abcdex \xff"[r.Intn(8)]
+		}
+		texts = append(texts, string(b))
+	}
+	texts = append(texts, "ああbい", "xabcd", "ABxC abcc")
+	for _, pattern := range patterns {
+		m, err := NewWindowMatcher(pattern)
+		if err != nil {
+			t.Fatalf("unexpected error, %v", err)
+		}
+		re := regexp.MustCompile(pattern)
+		for _, text := range texts {
+			b := []byte(text)
+			if got, want := m.Match(b), re.Match(b); got != want {
+				t.Errorf("%s: Match(%q) = %v, want %v", pattern, text, got, want)
+			}
+			if got, want := m.FindIndex(b), re.FindIndex(b); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: FindIndex(%q) = %v, want %v", pattern, text, got, want)
+			}
+			for _, n := range []int{-1, 0, 2} {
+				if got, want := m.FindAllIndex(b, n), re.FindAllIndex(b, n); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: FindAllIndex(%q, %d) = %v, want %v", pattern, text, n, got, want)
+				}
+			}
+		}
+	}
+	if _, err := NewWindowMatcher(`a(`); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func BenchmarkWindowMatcher_FindAllIndex(b *testing.B) {
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 1<<15)
+	copy(text[len(text)/2:], "error: 404 not found")
+	pattern := `error: \d{3} not found`
+	b.Run("regexp", func(b *testing.B) {
+		re := regexp.MustCompile(pattern)
+		for i := 0; i < b.N; i++ {
+			re.FindAllIndex(text, -1)
+		}
+	})
+	b.Run("window", func(b *testing.B) {
+		m, err := NewWindowMatcher(pattern)
+		if err != nil {
+			b.Fatalf("unexpected error, %v", err)
+		}
+		for i := 0; i < b.N; i++ {
+			m.FindAllIndex(text, -1)
+		}
+	})
+}